@@ -0,0 +1,106 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/reusee/keep/ledger"
+)
+
+// configFileName is the name of the config file looked up in the directory of the first ledger file
+const configFileName = "keep.json"
+
+// config is the content of the config file. Absent fields keep the defaults in defs.go
+type config struct {
+	ItemKinds       []string `json:"itemKinds"`
+	ConsumableKinds []string `json:"consumableKinds"`
+	// ConsumablesViewKinds are expense kinds listed in the consumables view
+	ConsumablesViewKinds []string `json:"consumablesViewKinds"`
+	// LiquidAssets are second level asset accounts available at T+0
+	LiquidAssets []string `json:"liquidAssets"`
+	// SortWeights order accounts in the tree, accounts with smaller weights are printed first, accounts without weights before all weighted ones
+	SortWeights []configSortWeight `json:"sortWeights"`
+	// AccountTypes maps root account names to asset, liability, income, expense or equity, replacing the defaults
+	AccountTypes map[string]ledger.AccountType `json:"accountTypes"`
+	// PayeeAliases maps payee names to the variants merged into them
+	PayeeAliases map[string][]string `json:"payeeAliases"`
+}
+
+type configSortWeight struct {
+	Level  int    `json:"level"`
+	Name   string `json:"name"`
+	Weight int    `json:"weight"`
+}
+
+// loadConfig reads the config file at path and applies it.
+// If path is empty, keep.json next to the ledger file is read if it exists
+func loadConfig(path string, ledgerPath string) {
+	if path == "" {
+		path = filepath.Join(filepath.Dir(ledgerPath), configFileName)
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			return
+		}
+	}
+	content, err := ioutil.ReadFile(path)
+	ce(err, "read config")
+	var c config
+	decoder := json.NewDecoder(bytes.NewReader(content))
+	decoder.DisallowUnknownFields()
+	ce(decoder.Decode(&c), "parse config %s", path)
+	for name, t := range c.AccountTypes {
+		if !t.Valid() {
+			ce(me(nil, "bad account type of %s: %s", name, t), "parse config %s", path)
+		}
+	}
+	aliased := make(map[string]string)
+	for payee, variants := range c.PayeeAliases {
+		for _, variant := range variants {
+			if other, ok := aliased[variant]; ok {
+				ce(me(nil, "payee alias %s of both %s and %s", variant, other, payee), "parse config %s", path)
+			}
+			aliased[variant] = payee
+		}
+	}
+	c.apply()
+}
+
+func (c *config) apply() {
+	if c.ItemKinds != nil {
+		itemKinds = make(map[string]bool)
+		for _, kind := range c.ItemKinds {
+			itemKinds[kind] = true
+		}
+	}
+	if c.ConsumableKinds != nil {
+		consumableKinds = make(map[string]bool)
+		for _, kind := range c.ConsumableKinds {
+			consumableKinds[kind] = true
+		}
+	}
+	if c.ConsumablesViewKinds != nil {
+		consumablesViewKinds = c.ConsumablesViewKinds
+	}
+	if c.LiquidAssets != nil {
+		liquidAssets = c.LiquidAssets
+	}
+	if c.AccountTypes != nil {
+		accountTypes = c.AccountTypes
+	}
+	if c.PayeeAliases != nil {
+		payeeAliases = make(map[string]string)
+		for payee, variants := range c.PayeeAliases {
+			for _, variant := range variants {
+				payeeAliases[variant] = payee
+			}
+		}
+	}
+	if c.SortWeights != nil {
+		sortWeight = make(map[sortWeightKey]int)
+		for _, w := range c.SortWeights {
+			sortWeight[sortWeightKey{w.Level, w.Name}] = w.Weight
+		}
+	}
+}
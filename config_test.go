@@ -0,0 +1,98 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/reusee/keep/ledger"
+)
+
+// testLoadConfig writes content to keep.json in a temp dir and loads it as the config of a ledger file in the dir.
+// restore resets the globals changed by the config
+func testLoadConfig(t *testing.T, content string) (restore func(), err error) {
+	items, consumables, viewKinds, liquid, weights := itemKinds, consumableKinds, consumablesViewKinds, liquidAssets, sortWeight
+	types, aliases := accountTypes, payeeAliases
+	restore = func() {
+		itemKinds, consumableKinds, consumablesViewKinds, liquidAssets, sortWeight = items, consumables, viewKinds, liquid, weights
+		accountTypes, payeeAliases = types, aliases
+	}
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if content != "" {
+		if err := ioutil.WriteFile(filepath.Join(dir, configFileName), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	defer he(&err)
+	loadConfig("", filepath.Join(dir, "keep.ledger"))
+	return
+}
+
+func TestLoadConfig(t *testing.T) {
+	restore, err := testLoadConfig(t, `{
+		"itemKinds": ["数码", "书"],
+		"consumableKinds": ["书"],
+		"liquidAssets": ["现金"],
+		"accountTypes": {"钱包": "asset", "花销": "expense"},
+		"payeeAliases": {"京东": ["JD", "jd.com"]},
+		"sortWeights": [{"level": 1, "name": "资产", "weight": -1}]
+	}`)
+	defer restore()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(itemKinds) != 2 || !itemKinds["数码"] || !itemKinds["书"] {
+		t.Fatalf("got %v", itemKinds)
+	}
+	if len(consumableKinds) != 1 || !consumableKinds["书"] {
+		t.Fatalf("got %v", consumableKinds)
+	}
+	if strings.Join(liquidAssets, " ") != "现金" {
+		t.Fatalf("got %v", liquidAssets)
+	}
+	if len(accountTypes) != 2 || accountTypes["钱包"] != ledger.AssetAccount || accountTypes["花销"] != ledger.ExpenseAccount {
+		t.Fatalf("got %v", accountTypes)
+	}
+	if len(payeeAliases) != 2 || payeeAliases["JD"] != "京东" || payeeAliases["jd.com"] != "京东" {
+		t.Fatalf("got %v", payeeAliases)
+	}
+	if len(sortWeight) != 1 || sortWeight[sortWeightKey{1, "资产"}] != -1 {
+		t.Fatalf("got %v", sortWeight)
+	}
+}
+
+func TestLoadConfigAbsent(t *testing.T) {
+	liquid := liquidAssets
+	restore, err := testLoadConfig(t, "")
+	defer restore()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(liquidAssets, " ") != strings.Join(liquid, " ") {
+		t.Fatalf("got %v", liquidAssets)
+	}
+}
+
+func TestLoadConfigError(t *testing.T) {
+	for _, c := range []struct {
+		content string
+		err     string
+	}{
+		{`{"liquidAsset": ["现金"]}`, `unknown field "liquidAsset"`},
+		{`{"itemKinds": "数码"}`, "cannot unmarshal string"},
+		{`{"accountTypes": {"钱包": "wallet"}}`, "bad account type of 钱包: wallet"},
+		{`{"payeeAliases": {"京东": ["JD"], "京东商城": ["JD"]}}`, "payee alias JD of both"},
+	} {
+		restore, err := testLoadConfig(t, c.content)
+		restore()
+		if err == nil || !strings.Contains(err.Error(), c.err) {
+			t.Errorf("%s: expecting %s, got %v", c.content, c.err, err)
+		}
+	}
+}
@@ -1,5 +1,12 @@
 package main
 
+import (
+	"sort"
+
+	"github.com/reusee/keep/ledger"
+)
+
+// default categories, may be overridden by the config file
 var (
 	itemKinds = map[string]bool{
 		"数码":   true,
@@ -14,6 +21,31 @@ var (
 		"药物":  true,
 	}
 
+	// consumablesViewKinds are expense kinds listed in the consumables view
+	consumablesViewKinds = []string{
+		"饮食",
+		"消耗品",
+		"药物",
+		"保健品",
+	}
+
+	// liquidAssets are second level asset accounts available at T+0
+	liquidAssets = []string{
+		"工行",
+		"建行",
+		"中信",
+		"兴业",
+		"现金",
+		"微信钱包",
+		"京东金库",
+	}
+
+	// accountTypes maps root account names to types
+	accountTypes = ledger.DefaultAccountTypes
+
+	// payeeAliases maps payee variants to the payees they are merged into
+	payeeAliases map[string]string
+
 	sortWeight = map[sortWeightKey]int{
 		{1, "基金"}:    -1,
 		{1, "保险"}:    1,
@@ -28,3 +60,18 @@ type sortWeightKey struct {
 	Level int
 	Name  string
 }
+
+// thingKinds returns the item and consumable kinds, sorted
+func thingKinds() []string {
+	var kinds []string
+	for kind := range itemKinds {
+		kinds = append(kinds, kind)
+	}
+	for kind := range consumableKinds {
+		if !itemKinds[kind] {
+			kinds = append(kinds, kind)
+		}
+	}
+	sort.Strings(kinds)
+	return kinds
+}
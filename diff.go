@@ -0,0 +1,158 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// unifiedDiff writes the unified diff of two texts with 3 lines of context
+func unifiedDiff(w io.Writer, pathA string, pathB string, a string, b string) {
+	linesA := splitLines(a)
+	linesB := splitLines(b)
+
+	edits := diffLines(linesA, linesB)
+
+	// hunks
+	const context = 3
+	headerWritten := false
+	for start := 0; start < len(edits); {
+		if edits[start].Op == ' ' {
+			start++
+			continue
+		}
+		// extend the hunk while changes are within 2*context lines
+		end := start
+		for k := start; k < len(edits); k++ {
+			if edits[k].Op != ' ' {
+				end = k
+			} else if k-end > 2*context {
+				break
+			}
+		}
+		from := start - context
+		if from < 0 {
+			from = 0
+		}
+		to := end + context + 1
+		if to > len(edits) {
+			to = len(edits)
+		}
+		if !headerWritten {
+			fmt.Fprintf(w, "--- %s\n+++ %s\n", pathA, pathB)
+			headerWritten = true
+		}
+		countA, countB := 0, 0
+		for _, e := range edits[from:to] {
+			if e.Op != '+' {
+				countA++
+			}
+			if e.Op != '-' {
+				countB++
+			}
+		}
+		fmt.Fprintf(w, "@@ -%s +%s @@\n", hunkRange(edits[from].A, countA), hunkRange(edits[from].B, countB))
+		for _, e := range edits[from:to] {
+			fmt.Fprintf(w, "%c%s\n", e.Op, e.Line)
+		}
+		start = to
+	}
+}
+
+type edit struct {
+	Op   byte
+	Line string
+	// A and B are line indexes in the texts before the edit
+	A, B int
+}
+
+// diffLines computes the shortest edit script with the Myers algorithm
+func diffLines(a []string, b []string) []edit {
+	n, m := len(a), len(b)
+	max := n + m
+	offset := max + 1
+	v := make([]int, 2*max+3)
+	// trace[d] holds v[k] for k in [-d, d] before step d
+	var trace [][]int
+	var d int
+loop:
+	for d = 0; d <= max; d++ {
+		trace = append(trace, append([]int(nil), v[offset-d:offset+d+1]...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				break loop
+			}
+		}
+	}
+
+	// backtrack
+	var edits []edit
+	x, y := n, m
+	for ; d > 0; d-- {
+		snapshot := trace[d]
+		at := func(k int) int {
+			return snapshot[k+d]
+		}
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && at(k-1) < at(k+1)) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := at(prevK)
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			x--
+			y--
+			edits = append(edits, edit{' ', a[x], x, y})
+		}
+		if x == prevX {
+			y--
+			edits = append(edits, edit{'+', b[y], x, y})
+		} else {
+			x--
+			edits = append(edits, edit{'-', a[x], x, y})
+		}
+	}
+	for x > 0 && y > 0 {
+		x--
+		y--
+		edits = append(edits, edit{' ', a[x], x, y})
+	}
+
+	for i, j := 0, len(edits)-1; i < j; i, j = i+1, j-1 {
+		edits[i], edits[j] = edits[j], edits[i]
+	}
+	return edits
+}
+
+func hunkRange(start int, count int) string {
+	if count == 0 {
+		return fmt.Sprintf("%d,0", start)
+	}
+	if count == 1 {
+		return fmt.Sprintf("%d", start+1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, count)
+}
+
+func splitLines(s string) []string {
+	s = strings.TrimSuffix(s, "\n")
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, "\n")
+}
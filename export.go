@@ -0,0 +1,37 @@
+package main
+
+import (
+	"encoding/csv"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/reusee/keep/ledger"
+)
+
+// exportCSV writes entries as CSV to stdout
+func exportCSV(l *ledger.Ledger) {
+	w := csv.NewWriter(os.Stdout)
+	ce(w.Write([]string{"date", "payee", "transaction", "account", "currency", "amount", "description", "tags"}))
+	for _, transaction := range l.Transactions {
+		for _, entry := range transaction.Entries {
+			var tags []string
+			for tag := range entry.Tags {
+				tags = append(tags, strings.TrimSuffix(strings.TrimPrefix(tag, "<"), ">"))
+			}
+			sort.Strings(tags)
+			ce(w.Write([]string{
+				entry.Time.Format("2006-01-02"),
+				transaction.Payee,
+				transaction.Description,
+				strings.Join(entry.Account.Path(), "："),
+				entry.Currency,
+				ledger.DecimalString(entry.Amount),
+				entry.Description,
+				strings.Join(tags, " "),
+			}))
+		}
+	}
+	w.Flush()
+	ce(w.Error())
+}
@@ -0,0 +1,270 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/reusee/keep/ledger"
+)
+
+var (
+	blanksPattern      = regexp.MustCompile(`\s+`)
+	commentLinePattern = regexp.MustCompile(`^\s*(#|//)`)
+)
+
+// formatLedger formats all files of the ledger.
+// If write is true, changed files are rewritten; if diff is true, changes are printed as unified diff.
+// If alignFile is true, columns are aligned across all transactions of a file instead of per transaction.
+// Returns paths of files not in formatted form
+func formatLedger(l *ledger.Ledger, write bool, diff bool, alignFile bool) (unformatted []string) {
+	for _, file := range l.Files {
+		if file.Path == "" {
+			continue
+		}
+		contentBytes, err := ioutil.ReadFile(file.Path)
+		ce(err, "read ledger")
+		out := formatFile(file, alignFile)
+		if bytes.Equal(contentBytes, out) {
+			continue
+		}
+		if !sameContent(file.Bytes(), out) {
+			ce(fmt.Errorf("formatting changes content of %s", file.Path))
+		}
+		unformatted = append(unformatted, file.Path)
+		if diff {
+			unifiedDiff(os.Stdout, file.Path, file.Path, string(contentBytes), string(out))
+		}
+		if write {
+			ce(ioutil.WriteFile(file.Path+".tmp", out, 0644))
+			ce(os.Rename(file.Path+".tmp", file.Path))
+		}
+	}
+	return
+}
+
+// formatNode is a block with the comment blocks before it, sorted as a whole
+type formatNode struct {
+	blocks []*ledger.Block
+	date   string
+}
+
+// formatFile returns the formatted content of file.
+// Blocks are sorted by date, comment blocks move with the block that follows them,
+// except the first comment block of file and comments after the last block, which stay in place.
+// Blank lines between blocks are preserved, with at least one
+func formatFile(file *ledger.File, alignFile bool) []byte {
+	var head, nodes, tail []*formatNode
+	var pending []*ledger.Block
+	for _, block := range file.Blocks {
+		pending = append(pending, block)
+		if block.Kind == ledger.CommentBlock {
+			continue
+		}
+		node := &formatNode{
+			blocks: pending,
+			date:   block.HeaderDate,
+		}
+		pending = nil
+		nodes = append(nodes, node)
+	}
+	if len(pending) > 0 {
+		tail = append(tail, &formatNode{
+			blocks: pending,
+		})
+	}
+	if len(nodes) > 0 && len(nodes[0].blocks) > 1 {
+		// the first comment block of file is the file header
+		first := nodes[0]
+		head = append(head, &formatNode{
+			blocks: first.blocks[:1],
+		})
+		first.blocks = first.blocks[1:]
+	}
+	sort.SliceStable(nodes, func(i, j int) bool {
+		return nodes[i].date < nodes[j].date
+	})
+
+	var columns *entryColumns
+	if alignFile {
+		columns = new(entryColumns)
+		for _, block := range file.Blocks {
+			if block.Kind == ledger.TransactionBlock {
+				columns.add(block)
+			}
+		}
+	}
+
+	out := new(bytes.Buffer)
+	write := func(s string) {
+		if _, err := out.WriteString(s); err != nil {
+			panic(err)
+		}
+	}
+	n := 0
+	for _, node := range append(append(head, nodes...), tail...) {
+		for _, block := range node.blocks {
+			if n > 0 {
+				write("\n")
+				for i := 1; i < len(block.Leading); i++ {
+					write("\n")
+				}
+			}
+			n++
+			switch block.Kind {
+			case ledger.CommentBlock:
+				for _, line := range block.Raw {
+					write(strings.TrimRightFunc(line, unicode.IsSpace) + "\n")
+				}
+			case ledger.DirectiveBlock:
+				for _, line := range block.Contents {
+					write(line + "\n")
+				}
+			default:
+				write(formatTransaction(block, columns))
+			}
+		}
+	}
+	if n > 0 {
+		write("\n")
+	}
+	return out.Bytes()
+}
+
+// entryColumns holds the column widths of entry lines
+type entryColumns struct {
+	account int
+	// point is the width of the amount before the decimal point
+	point  int
+	amount int
+}
+
+func splitEntryLine(line string) []string {
+	if strings.HasPrefix(line, "=") {
+		// balance assertion
+		parts := blanksPattern.Split(strings.TrimSpace(line[1:]), 3)
+		parts[0] = "= " + parts[0]
+		return parts
+	}
+	return blanksPattern.Split(line, 3)
+}
+
+// amountPoint returns the display width of amount before the decimal point of its first number
+func amountPoint(amount string) int {
+	digits := false
+	for i, r := range amount {
+		if r >= '0' && r <= '9' {
+			digits = true
+			continue
+		}
+		if digits {
+			return displayWidth(amount[:i])
+		}
+	}
+	return displayWidth(amount)
+}
+
+func (c *entryColumns) add(block *ledger.Block) {
+	var entries []string
+	for n := block.Header() + 1; n < len(block.Contents); n++ {
+		if _, _, ok := block.Metadata(n); ok || commentLinePattern.MatchString(block.Contents[n]) {
+			continue
+		}
+		entries = append(entries, block.Contents[n])
+	}
+	for _, line := range entries {
+		parts := splitEntryLine(line)
+		if width := displayWidth(parts[0]); width > c.account {
+			c.account = width
+		}
+		if len(parts) > 1 {
+			if point := amountPoint(parts[1]); point > c.point {
+				c.point = point
+			}
+		}
+	}
+	for _, line := range entries {
+		parts := splitEntryLine(line)
+		if len(parts) > 1 {
+			width := c.point - amountPoint(parts[1]) + displayWidth(parts[1])
+			if width > c.amount {
+				c.amount = width
+			}
+		}
+	}
+}
+
+// formatTransaction formats a transaction block.
+// Amounts are aligned on the decimal point, so integer parts are right-aligned, metadata lines are indented by two spaces.
+// If columns is nil, widths are computed in the block
+func formatTransaction(block *ledger.Block, columns *entryColumns) string {
+	if columns == nil {
+		columns = new(entryColumns)
+		columns.add(block)
+	}
+	b := new(strings.Builder)
+	h := block.Header()
+	for _, line := range block.Contents[:h+1] {
+		b.WriteString(line + "\n")
+	}
+	for n := h + 1; n < len(block.Contents); n++ {
+		line := block.Contents[n]
+		if commentLinePattern.MatchString(line) {
+			b.WriteString(line + "\n")
+			continue
+		}
+		if _, _, ok := block.Metadata(n); ok {
+			b.WriteString("  " + line + "\n")
+			continue
+		}
+		parts := splitEntryLine(line)
+		if len(parts) > 1 {
+			parts[1] = strings.Repeat(" ", columns.point-amountPoint(parts[1])) + parts[1]
+		}
+		widths := []int{columns.account, columns.amount}
+		for i, part := range parts {
+			if i > 0 && len(part) > 0 {
+				b.WriteString("    ")
+			}
+			if i == len(parts)-1 {
+				part = strings.TrimRight(part, " ")
+			} else {
+				part = padToLen(part, widths[i])
+			}
+			b.WriteString(part)
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// sameContent reports whether a and b have the same non-blank lines, ignoring order and blanks
+func sameContent(a, b []byte) bool {
+	normalize := func(content []byte) []string {
+		var lines []string
+		for _, line := range strings.Split(string(content), "\n") {
+			line = strings.Join(strings.Fields(line), "")
+			if line != "" {
+				lines = append(lines, line)
+			}
+		}
+		sort.Strings(lines)
+		return lines
+	}
+	linesA := normalize(a)
+	linesB := normalize(b)
+	if len(linesA) != len(linesB) {
+		return false
+	}
+	for i, line := range linesA {
+		if line != linesB[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/reusee/keep/ledger"
+)
+
+func TestFormatFileIdempotent(t *testing.T) {
+	for _, content := range []string{
+		"2020-01-01 a\n资产：现金 ￥1\n收入：工资 ￥-1\n",
+		// header comment, unsorted blocks with comments, extra blank lines, trailing comment
+		`// header
+
+2020-02-01 b
+资产：现金    ￥12.5   午饭
+支出：饮食   ￥-12.5
+
+
+# about a
+2020-01-01 a
+资产：工行 ￥1000
+收入：工资 ￥-1000
+= 资产：工行 ￥1000
+
+// trailing
+`,
+		// directives, metadata, payee, lots
+		`open 2020-01-01 资产：券商
+open	2020-01-01 资产：工行
+
+2020-01-02 券商 | buy
+	receipt:   a.pdf
+资产：券商 AAPL10{$100}
+  note: first
+资产：工行 $-1000
+
+# comment
+2020-01-03 sell
+// in transaction
+资产：券商 AAPL-5{}@$150
+资产：工行 $750
+收入：投资收益 $-250
+`,
+	} {
+		for _, alignFile := range []bool{false, true} {
+			l, err := ledger.Parse(strings.NewReader(content))
+			if err != nil {
+				t.Fatal(err)
+			}
+			out := formatFile(l.Files[0], alignFile)
+			if !sameContent([]byte(content), out) {
+				t.Fatalf("content changed:\n%s", out)
+			}
+			l, err = ledger.Parse(strings.NewReader(string(out)))
+			if err != nil {
+				t.Fatalf("%v\n%s", err, out)
+			}
+			out2 := formatFile(l.Files[0], alignFile)
+			if string(out2) != string(out) {
+				t.Fatalf("not idempotent:\n%s\n----\n%s", out, out2)
+			}
+		}
+	}
+}
+
+func TestSameContent(t *testing.T) {
+	if !sameContent([]byte("a b\n\nc\n"), []byte("c\n  a  b\n")) {
+		t.Fatal("expecting same content")
+	}
+	if sameContent([]byte("a\nb\n"), []byte("a\n")) {
+		t.Fatal("expecting different content")
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"math/big"
+	"sort"
+	"strings"
+
+	"github.com/reusee/keep/ledger"
+)
+
+// printGains prints realized gains of reduced lots, and yearly totals per currency
+func printGains(l *ledger.Ledger) {
+	var rows [][]string
+	type totalKey struct {
+		Year     int
+		Currency string
+	}
+	totals := make(map[totalKey]*big.Rat)
+	for _, gain := range l.Gains {
+		rows = append(rows, []string{
+			gain.Transaction.Date.Format("2006-01-02"),
+			strings.Join(gain.Entry.Account.Path(), "："),
+			ledger.FormatAmount(gain.Lot.Commodity, gain.Quantity, 2),
+			gain.Lot.Date.Format("2006-01-02"),
+			ledger.FormatAmount(gain.Currency, gain.Cost, 2),
+			ledger.FormatAmount(gain.Currency, gain.Proceeds, 2),
+			ledger.FormatAmount(gain.Currency, gain.Gain, 2),
+		})
+		key := totalKey{gain.Transaction.Date.Year(), gain.Currency}
+		total, ok := totals[key]
+		if !ok {
+			total = new(big.Rat)
+			totals[key] = total
+		}
+		total.Add(total, gain.Gain)
+	}
+	if len(rows) == 0 {
+		return
+	}
+	printTable([]string{"date", "account", "quantity", "acquired", "cost", "proceeds", "gain"}, rows)
+
+	var keys []totalKey
+	for key := range totals {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].Year != keys[j].Year {
+			return keys[i].Year < keys[j].Year
+		}
+		return keys[i].Currency < keys[j].Currency
+	})
+	pt("\n")
+	for _, key := range keys {
+		pt("%d %s\n", key.Year, ledger.FormatAmount(key.Currency, totals[key], 2))
+	}
+}
+
+// printTable prints rows in columns aligned by display width
+func printTable(header []string, rows [][]string) {
+	widths := make([]int, len(header))
+	for _, row := range append([][]string{header}, rows...) {
+		for i, cell := range row {
+			if w := displayWidth(cell); w > widths[i] {
+				widths[i] = w
+			}
+		}
+	}
+	for _, row := range append([][]string{header}, rows...) {
+		for i, cell := range row {
+			if i == len(row)-1 {
+				pt("%s\n", cell)
+			} else {
+				pt("%s  ", padToLen(cell, widths[i]))
+			}
+		}
+	}
+}
@@ -4,7 +4,7 @@ require (
 	github.com/go-sql-driver/mysql v1.4.1 // indirect
 	github.com/jmoiron/sqlx v1.2.0
 	github.com/lib/pq v1.3.0
-	github.com/mattn/go-sqlite3 v1.10.0 // indirect
+	github.com/mattn/go-sqlite3 v1.14.16
 	github.com/reusee/e/v2 v2.3.3
 	golang.org/x/text v0.3.8
 	google.golang.org/appengine v1.4.0 // indirect
@@ -0,0 +1,99 @@
+package ledger
+
+import (
+	"math/big"
+	"time"
+)
+
+type Account struct {
+	Name        string
+	Subs        map[string]*Account
+	Parent      *Account
+	Balances    map[string]*big.Rat
+	Proportions map[string]*big.Rat
+	TimeFrom    time.Time
+
+	// OpenDate and CloseDate are set by open and close directives
+	OpenDate  time.Time
+	CloseDate time.Time
+	// Currencies allowed by the open directive, empty for any
+	Currencies []string
+}
+
+func newAccount(name string, parent *Account) *Account {
+	return &Account{
+		Name:        name,
+		Subs:        make(map[string]*Account),
+		Parent:      parent,
+		Balances:    make(map[string]*big.Rat),
+		Proportions: make(map[string]*big.Rat),
+	}
+}
+
+func getAccount(root *Account, path []string) *Account {
+	if len(path) == 0 {
+		panic(me(nil, "bad account: %v", path))
+	}
+	name := path[0]
+	account, ok := root.Subs[name]
+	if !ok {
+		account = newAccount(name, root)
+		root.Subs[name] = account
+	}
+	if len(path) == 1 {
+		return account
+	}
+	return getAccount(account, path[1:])
+}
+
+// findAccount returns the account at path, or nil if not exists
+func findAccount(root *Account, path []string) *Account {
+	account := root
+	for _, name := range path {
+		account = account.Subs[name]
+		if account == nil {
+			return nil
+		}
+	}
+	return account
+}
+
+// Declaration returns the nearest account opened by an open directive, starting from a itself
+func (a *Account) Declaration() *Account {
+	for acc := a; acc != nil; acc = acc.Parent {
+		if !acc.OpenDate.IsZero() {
+			return acc
+		}
+	}
+	return nil
+}
+
+func (a *Account) Top() *Account {
+	ret := a
+	for ret.Parent != nil && ret.Parent.Parent != nil {
+		ret = ret.Parent
+	}
+	return ret
+}
+
+func (a *Account) MatchPath(path []string) bool {
+	c := a
+	for i := len(path) - 1; i >= 0; i-- {
+		if c.Name == path[i] {
+			c = c.Parent
+		}
+	}
+	return c.Parent == nil // is root
+}
+
+// Path returns account names from the top-level account to a, excluding root
+func (a *Account) Path() (ret []string) {
+	for acc := a; acc.Parent != nil; acc = acc.Parent {
+		ret = append(ret, acc.Name)
+	}
+	for i := len(ret)/2 - 1; i >= 0; i-- {
+		j := len(ret) - 1 - i
+		ret[i], ret[j] = ret[j], ret[i]
+	}
+	return
+}
@@ -0,0 +1,56 @@
+package ledger
+
+import "sort"
+
+// AccountType is the semantic type of the accounts under a root account
+type AccountType string
+
+const (
+	AssetAccount     AccountType = "asset"
+	LiabilityAccount AccountType = "liability"
+	IncomeAccount    AccountType = "income"
+	ExpenseAccount   AccountType = "expense"
+	EquityAccount    AccountType = "equity"
+)
+
+// AccountTypes maps root account names to types
+type AccountTypes map[string]AccountType
+
+// DefaultAccountTypes is used if Parser.AccountTypes is nil
+var DefaultAccountTypes = AccountTypes{
+	"资产":          AssetAccount,
+	"负债":          LiabilityAccount,
+	"收入":          IncomeAccount,
+	"支出":          ExpenseAccount,
+	"权益":          EquityAccount,
+	"Assets":      AssetAccount,
+	"Liabilities": LiabilityAccount,
+	"Income":      IncomeAccount,
+	"Expenses":    ExpenseAccount,
+	"Equity":      EquityAccount,
+}
+
+// Valid reports whether t is one of the defined types
+func (t AccountType) Valid() bool {
+	switch t {
+	case AssetAccount, LiabilityAccount, IncomeAccount, ExpenseAccount, EquityAccount:
+		return true
+	}
+	return false
+}
+
+// Of returns the type of account by its root, empty if not mapped
+func (a AccountTypes) Of(account *Account) AccountType {
+	return a[account.Top().Name]
+}
+
+// Roots returns the sorted root names of type t
+func (a AccountTypes) Roots(t AccountType) (roots []string) {
+	for name, typ := range a {
+		if typ == t {
+			roots = append(roots, name)
+		}
+	}
+	sort.Strings(roots)
+	return
+}
@@ -0,0 +1,241 @@
+package ledger
+
+import (
+	"go/ast"
+	goparser "go/parser"
+	"go/token"
+	"math/big"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// parseCurrencyAmount parses an amount with a commodity.
+// The commodity is a prefix or a suffix of the expression. It may be a single currency symbol like ￥ $ /,
+// a sequence of letters like USD BTC, or a double-quoted string like "510300" for codes containing digits
+func parseCurrencyAmount(str string) (currency string, amount *big.Rat, err error) {
+	prefix, rest, err := splitCommodityPrefix(str)
+	if err != nil {
+		return
+	}
+	expr, suffix, err := splitCommoditySuffix(rest)
+	if err != nil {
+		return
+	}
+	switch {
+	case prefix != "" && suffix != "":
+		err = me(nil, "both prefix and suffix commodity: %s", str)
+		return
+	case prefix != "":
+		currency = prefix
+	case suffix != "":
+		currency = suffix
+	default:
+		err = me(nil, "missing commodity: %s", str)
+		return
+	}
+	amount, err = parseAmount(expr)
+	return
+}
+
+// parseEntryAmount parses the amount field of an entry: <amount>[{lot}][@price]
+func parseEntryAmount(entry *Entry, str string) error {
+	if i := strings.Index(str, "@"); i >= 0 {
+		currency, price, err := parseCurrencyAmount(str[i+1:])
+		if err != nil {
+			return err
+		}
+		if price.Sign() < 0 {
+			return me(nil, "negative price: %s", str[i+1:])
+		}
+		entry.Price = price
+		entry.PriceCurrency = currency
+		str = str[:i]
+	}
+	if i := strings.Index(str, "{"); i >= 0 {
+		if !strings.HasSuffix(str, "}") {
+			return me(nil, "unterminated lot: %s", str)
+		}
+		spec, err := parseLotSpec(str[i+1 : len(str)-1])
+		if err != nil {
+			return err
+		}
+		entry.Lot = spec
+		str = str[:i]
+	}
+	currency, amount, err := parseCurrencyAmount(str)
+	if err != nil {
+		return err
+	}
+	entry.Currency = currency
+	entry.Amount = amount
+	return nil
+}
+
+func splitCommodityPrefix(str string) (commodity string, rest string, err error) {
+	if strings.HasPrefix(str, `"`) {
+		end := strings.Index(str[1:], `"`)
+		if end < 0 {
+			return "", "", me(nil, "unterminated commodity: %s", str)
+		}
+		return str[1 : end+1], str[end+2:], nil
+	}
+	r, size := utf8.DecodeRuneInString(str)
+	switch {
+	case unicode.IsLetter(r):
+		end := strings.IndexFunc(str, func(r rune) bool {
+			return !unicode.IsLetter(r)
+		})
+		if end < 0 {
+			end = len(str)
+		}
+		return str[:end], str[end:], nil
+	case isExprRune(r):
+		return "", str, nil
+	}
+	return str[:size], str[size:], nil
+}
+
+func splitCommoditySuffix(str string) (expr string, commodity string, err error) {
+	if strings.HasSuffix(str, `"`) {
+		begin := strings.LastIndex(str[:len(str)-1], `"`)
+		if begin < 0 {
+			return "", "", me(nil, "unterminated commodity: %s", str)
+		}
+		return str[:begin], str[begin+1 : len(str)-1], nil
+	}
+	r, size := utf8.DecodeLastRuneInString(str)
+	switch {
+	case unicode.IsLetter(r):
+		begin := strings.LastIndexFunc(str, func(r rune) bool {
+			return !unicode.IsLetter(r)
+		}) + 1
+		return str[:begin], str[begin:], nil
+	case unicode.Is(unicode.Sc, r):
+		return str[:len(str)-size], str[len(str)-size:], nil
+	}
+	return str, "", nil
+}
+
+func isExprRune(r rune) bool {
+	return unicode.IsDigit(r) || strings.ContainsRune(".+-(", r)
+}
+
+// FormatAmount formats amount with prec decimal digits and the commodity.
+// Single-rune commodities are prefixed like ￥42.00, others are suffixed like 42.00 USD
+func FormatAmount(currency string, amount *big.Rat, prec int) string {
+	if utf8.RuneCountInString(currency) == 1 {
+		return currency + amount.FloatString(prec)
+	}
+	return amount.FloatString(prec) + " " + currency
+}
+
+// maxDecimalDigits is the number of decimal digits of amounts without a finite decimal form, like 1/3
+const maxDecimalDigits = 18
+
+// DecimalString formats amount as a decimal without rounding,
+// amounts without a finite decimal form are rounded to 18 decimal digits
+func DecimalString(amount *big.Rat) string {
+	// the decimal form is finite if the denominator has only factors 2 and 5
+	denom := new(big.Int).Set(amount.Denom())
+	twos := 0
+	for denom.Bit(0) == 0 {
+		denom.Rsh(denom, 1)
+		twos++
+	}
+	fives := 0
+	five := big.NewInt(5)
+	for {
+		quo, rem := new(big.Int).QuoRem(denom, five, new(big.Int))
+		if rem.Sign() != 0 {
+			break
+		}
+		denom = quo
+		fives++
+	}
+	if denom.Cmp(big.NewInt(1)) != 0 {
+		return amount.FloatString(maxDecimalDigits)
+	}
+	digits := twos
+	if fives > digits {
+		digits = fives
+	}
+	return amount.FloatString(digits)
+}
+
+// formatAmount formats amount for diagnostics
+func formatAmount(currency string, amount *big.Rat) string {
+	return FormatAmount(currency, amount, 2)
+}
+
+func parseAmount(str string) (*big.Rat, error) {
+	expr, err := goparser.ParseExpr(str)
+	if err != nil {
+		return nil, err
+	}
+	value, err := evalExpr(expr)
+	if err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+func evalExpr(expr ast.Expr) (result *big.Rat, err error) {
+	defer he(&err)
+
+	switch expr := expr.(type) {
+
+	case *ast.BasicLit:
+		r := new(big.Rat)
+		a, ok := r.SetString(expr.Value)
+		if !ok {
+			return nil, me(nil, "bad expression: %s", expr.Value)
+		}
+		return a, nil
+
+	case *ast.UnaryExpr:
+		switch expr.Op {
+		case token.SUB:
+			v, err := evalExpr(expr.X)
+			ce(err)
+			return v.Neg(v), nil
+		}
+
+	case *ast.BinaryExpr:
+		x, err := evalExpr(expr.X)
+		ce(err)
+		y, err := evalExpr(expr.Y)
+		ce(err)
+		switch expr.Op {
+		case token.MUL:
+			return x.Mul(x, y), nil
+		case token.SUB:
+			return x.Sub(x, y), nil
+		case token.ADD:
+			return x.Add(x, y), nil
+		case token.QUO:
+			if y.Sign() == 0 {
+				return nil, me(nil, "division by zero")
+			}
+			return x.Quo(x, y), nil
+		}
+
+	case *ast.ParenExpr:
+		return evalExpr(expr.X)
+
+	}
+
+	return nil, me(nil, "unknown expr: %T", expr)
+}
+
+// ParseDate parses a date like 2006-01-02, / and . are also accepted as separators
+func ParseDate(str string) (time.Time, error) {
+	str = strings.Replace(str, "/", "-", -1)
+	str = strings.Replace(str, ".", "-", -1)
+	t, err := time.Parse("2006-01-02", str)
+	if err != nil {
+		return t, me(err, "bad date: %s", str)
+	}
+	return t, nil
+}
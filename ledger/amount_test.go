@@ -0,0 +1,68 @@
+package ledger
+
+import (
+	"math/big"
+	"testing"
+)
+
+func TestDecimalString(t *testing.T) {
+	for _, c := range []struct {
+		amount   string
+		expected string
+	}{
+		{"10", "10"},
+		{"-10", "-10"},
+		{"0", "0"},
+		{"1.5", "1.5"},
+		{"0.12345", "0.12345"},
+		{"-0.00012345", "-0.00012345"},
+		{"1/8", "0.125"},
+		{"1/3", "0.333333333333333333"},
+	} {
+		amount, _ := new(big.Rat).SetString(c.amount)
+		if got := DecimalString(amount); got != c.expected {
+			t.Errorf("%s: expecting %s, got %s", c.amount, c.expected, got)
+		}
+	}
+}
+
+func TestParseCurrencyAmount(t *testing.T) {
+	for _, c := range []struct {
+		str      string
+		currency string
+		amount   string
+		ok       bool
+	}{
+		{"￥1.5", "￥", "3/2", true},
+		{"￥-1.5", "￥", "-3/2", true},
+		{"$1+2*3", "$", "7", true},
+		{"$(1+2)/4", "$", "3/4", true},
+		{"USD100", "USD", "100", true},
+		{"100USD", "USD", "100", true},
+		{"-3BTC", "BTC", "-3", true},
+		{"10€", "€", "10", true},
+		{`"510300"1.5`, "510300", "3/2", true},
+		{`2"510300"`, "510300", "2", true},
+		{"AAPL10", "AAPL", "10", true},
+		{"1.5", "", "", false},
+		{"$1USD", "", "", false},
+		{`"510300`, "", "", false},
+		{"$1x2", "", "", false},
+	} {
+		currency, amount, err := parseCurrencyAmount(c.str)
+		if !c.ok {
+			if err == nil {
+				t.Errorf("%s: expecting error, got %s %v", c.str, currency, amount)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", c.str, err)
+			continue
+		}
+		expected, _ := new(big.Rat).SetString(c.amount)
+		if currency != c.currency || amount.Cmp(expected) != 0 {
+			t.Errorf("%s: expecting %s %s, got %s %s", c.str, c.currency, c.amount, currency, amount.RatString())
+		}
+	}
+}
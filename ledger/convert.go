@@ -0,0 +1,111 @@
+package ledger
+
+import (
+	"math/big"
+	"sort"
+	"time"
+)
+
+// Rate returns the amount of currency worth one unit of commodity at date.
+// Prices and exchange rates are used in both directions, and chained through intermediate currencies if no direct one is recorded
+func (p Prices) Rate(commodity string, currency string, date time.Time) (*big.Rat, bool) {
+	if rate, ok := p.directRate(commodity, currency, date); ok {
+		return rate, true
+	}
+
+	// breadth-first search for the shortest chain
+	rates := map[string]*big.Rat{
+		commodity: big.NewRat(1, 1),
+	}
+	queue := []string{commodity}
+	for len(queue) > 0 {
+		from := queue[0]
+		queue = queue[1:]
+		for _, to := range p.neighbors(from, date) {
+			if _, ok := rates[to]; ok {
+				continue
+			}
+			rate, ok := p.directRate(from, to, date)
+			if !ok {
+				continue
+			}
+			rate.Mul(rate, rates[from])
+			if to == currency {
+				return rate, true
+			}
+			rates[to] = rate
+			queue = append(queue, to)
+		}
+	}
+
+	return nil, false
+}
+
+// Convert converts amount of commodity to currency with rates at date
+func (p Prices) Convert(amount *big.Rat, commodity string, currency string, date time.Time) (*big.Rat, bool) {
+	rate, ok := p.Rate(commodity, currency, date)
+	if !ok {
+		return nil, false
+	}
+	return rate.Mul(rate, amount), true
+}
+
+// directRate returns the rate from the price of commodity in currency, or the inverse of the price of currency in commodity, whichever is newer
+func (p Prices) directRate(commodity string, currency string, date time.Time) (*big.Rat, bool) {
+	if commodity == currency {
+		return big.NewRat(1, 1), true
+	}
+	direct := p.Lookup(commodity, currency, date)
+	inverse := p.Lookup(currency, commodity, date)
+	if inverse != nil && inverse.Amount.Sign() != 0 &&
+		(direct == nil || inverse.Date.After(direct.Date)) {
+		return new(big.Rat).Inv(inverse.Amount), true
+	}
+	if direct != nil {
+		return new(big.Rat).Set(direct.Amount), true
+	}
+	return nil, false
+}
+
+// neighbors returns currencies that have a price relation with commodity at or before date, sorted
+func (p Prices) neighbors(commodity string, date time.Time) (ret []string) {
+	set := make(map[string]bool)
+	for _, price := range p[commodity] {
+		if !price.Date.After(date) {
+			set[price.Currency] = true
+		}
+	}
+	for c, history := range p {
+		for _, price := range history {
+			if price.Currency == commodity && !price.Date.After(date) {
+				set[c] = true
+				break
+			}
+		}
+	}
+	for c := range set {
+		ret = append(ret, c)
+	}
+	sort.Strings(ret)
+	return
+}
+
+// Tree returns a copy of the account tree with balances replaced by values in the valuation currency
+func (v *Valuation) Tree(root *Account) *Account {
+	var clone func(account *Account, parent *Account) *Account
+	clone = func(account *Account, parent *Account) *Account {
+		ret := newAccount(account.Name, parent)
+		ret.TimeFrom = account.TimeFrom
+		ret.OpenDate = account.OpenDate
+		ret.CloseDate = account.CloseDate
+		ret.Currencies = account.Currencies
+		if value, ok := v.Values[account]; ok {
+			ret.Balances[v.Currency] = new(big.Rat).Set(value)
+		}
+		for name, sub := range account.Subs {
+			ret.Subs[name] = clone(sub, ret)
+		}
+		return ret
+	}
+	return clone(root, nil)
+}
@@ -0,0 +1,61 @@
+package ledger
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPricesRate(t *testing.T) {
+	l, err := Parse(strings.NewReader(`price 2020-01-01 AAPL $100
+price 2020-02-01 AAPL $120
+rate 2020-01-01 $ ￥7
+rate 2020-01-01 HKD ￥0.9
+rate 2020-01-01 ￥ JPY20
+rate 2020-01-01 EUR $1.25
+rate 2020-03-01 $ EUR0.5
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []struct {
+		commodity string
+		currency  string
+		date      string
+		rate      string
+		ok        bool
+	}{
+		{"AAPL", "$", "2020-01-15", "100", true},
+		{"AAPL", "$", "2020-02-01", "120", true},
+		{"AAPL", "$", "2019-12-31", "", false},
+		{"$", "$", "2020-01-01", "1", true},
+		// inverse
+		{"$", "AAPL", "2020-01-15", "1/100", true},
+		{"￥", "$", "2020-01-15", "1/7", true},
+		// chained
+		{"AAPL", "￥", "2020-01-15", "700", true},
+		{"AAPL", "JPY", "2020-02-15", "16800", true},
+		{"HKD", "$", "2020-01-15", "9/70", true},
+		// the newer of direct and inverse rates
+		{"EUR", "$", "2020-01-15", "5/4", true},
+		{"EUR", "$", "2020-03-15", "2", true},
+		{"AAPL", "GBP", "2020-01-15", "", false},
+	} {
+		rate, ok := l.Prices.Rate(c.commodity, c.currency, mustParseDate(c.date))
+		if ok != c.ok {
+			t.Errorf("%s in %s at %s: expecting ok %v, got %v", c.commodity, c.currency, c.date, c.ok, ok)
+			continue
+		}
+		if ok && rate.RatString() != c.rate {
+			t.Errorf("%s in %s at %s: expecting %s, got %s", c.commodity, c.currency, c.date, c.rate, rate.RatString())
+		}
+	}
+}
+
+func mustParseDate(s string) time.Time {
+	t, err := ParseDate(s)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
@@ -0,0 +1,85 @@
+package ledger
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+type Severity int
+
+const (
+	SeverityError Severity = iota
+	SeverityWarning
+)
+
+func (s Severity) String() string {
+	switch s {
+	case SeverityError:
+		return "error"
+	case SeverityWarning:
+		return "warning"
+	}
+	return fmt.Sprintf("severity(%d)", int(s))
+}
+
+// Position is a location in a ledger file. Line and Column are 1-based, Column counts bytes
+type Position struct {
+	File   string
+	Line   int
+	Column int
+}
+
+func (p Position) String() string {
+	s := fmt.Sprintf("%d:%d", p.Line, p.Column)
+	if p.File != "" {
+		s = p.File + ":" + s
+	}
+	return s
+}
+
+// Diagnostic describes a problem found while parsing
+type Diagnostic struct {
+	Pos      Position
+	Severity Severity
+	Message  string
+}
+
+func (d *Diagnostic) Error() string {
+	return fmt.Sprintf("%s: %s: %s", d.Pos, d.Severity, d.Message)
+}
+
+// Diagnostics is a list of problems, used as the error returned by Parse
+type Diagnostics []*Diagnostic
+
+func (d Diagnostics) Error() string {
+	var lines []string
+	for _, diag := range d {
+		lines = append(lines, diag.Error())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Sort sorts diagnostics by file, line and column, keeping the order of diagnostics at the same position
+func (d Diagnostics) Sort() {
+	sort.SliceStable(d, func(i, j int) bool {
+		a, b := d[i].Pos, d[j].Pos
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Column < b.Column
+	})
+}
+
+// HasError reports whether any diagnostic is of error severity
+func (d Diagnostics) HasError() bool {
+	for _, diag := range d {
+		if diag.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,191 @@
+package ledger
+
+import (
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+var directiveKeywords = map[string]bool{
+	"include": true,
+	"open":    true,
+	"close":   true,
+	"price":   true,
+	"rate":    true,
+}
+
+// loadDirectives handles directives that must be processed while loading files
+func (p *parser) loadDirectives(block *Block) {
+	for n, line := range block.Contents {
+		if commentLinePattern.MatchString(line) {
+			continue
+		}
+		parts, offsets := splitFields(line, 2)
+		switch parts[0] {
+
+		case "include":
+			// include <glob>
+			if len(parts) != 2 {
+				p.report(block.Pos(n, 0), SeverityError, "bad include: missing path")
+				continue
+			}
+			pattern := parts[1]
+			if !filepath.IsAbs(pattern) && block.File != "" {
+				pattern = filepath.Join(filepath.Dir(block.File), pattern)
+			}
+			paths, err := filepath.Glob(pattern)
+			if err != nil {
+				p.report(block.Pos(n, offsets[1]), SeverityError, "bad include pattern: %s", parts[1])
+				continue
+			}
+			if len(paths) == 0 {
+				p.report(block.Pos(n, offsets[1]), SeverityError, "no file matches %s", parts[1])
+				continue
+			}
+			for _, path := range paths {
+				if err := p.loadFile(path); err != nil {
+					p.report(block.Pos(n, offsets[1]), SeverityError, "include: %v", err)
+				}
+			}
+
+		default:
+			if !directiveKeywords[parts[0]] {
+				p.report(block.Pos(n, 0), SeverityError, "unknown directive: %s", parts[0])
+			}
+
+		}
+	}
+}
+
+// parseDirectives handles directives after all files are loaded
+func (p *parser) parseDirectives(block *Block) {
+	for n, line := range block.Contents {
+		if commentLinePattern.MatchString(line) {
+			continue
+		}
+		parts, offsets := splitFields(line, -1)
+		switch parts[0] {
+
+		case "open":
+			// open <date> <account> [currency...]
+			if len(parts) < 3 {
+				p.report(block.Pos(n, 0), SeverityError, "bad open: expecting date and account")
+				continue
+			}
+			date, err := ParseDate(parts[1])
+			if err != nil {
+				p.report(block.Pos(n, offsets[1]), SeverityError, "bad date: %s", parts[1])
+				continue
+			}
+			account := getAccount(p.ledger.Root, accountSeparatePattern.Split(parts[2], -1))
+			if !account.OpenDate.IsZero() {
+				p.report(block.Pos(n, offsets[2]), SeverityError, "account already opened: %s", parts[2])
+				continue
+			}
+			account.OpenDate = date
+			account.Currencies = parts[3:]
+
+		case "close":
+			// close <date> <account>
+			if len(parts) != 3 {
+				p.report(block.Pos(n, 0), SeverityError, "bad close: expecting date and account")
+				continue
+			}
+			date, err := ParseDate(parts[1])
+			if err != nil {
+				p.report(block.Pos(n, offsets[1]), SeverityError, "bad date: %s", parts[1])
+				continue
+			}
+			account := findAccount(p.ledger.Root, accountSeparatePattern.Split(parts[2], -1))
+			if account == nil || account.OpenDate.IsZero() {
+				p.report(block.Pos(n, offsets[2]), SeverityError, "closing undeclared account: %s", parts[2])
+				continue
+			}
+			if !account.CloseDate.IsZero() {
+				p.report(block.Pos(n, offsets[2]), SeverityError, "account already closed: %s", parts[2])
+				continue
+			}
+			if date.Before(account.OpenDate) {
+				p.report(block.Pos(n, offsets[1]), SeverityError, "account closed before opened: %s", parts[2])
+				continue
+			}
+			account.CloseDate = date
+
+		case "price", "rate":
+			// price <date> <commodity> <amount>
+			// rate <date> <currency> <amount>
+			if len(parts) != 4 {
+				p.report(block.Pos(n, 0), SeverityError, "bad %s: expecting date, commodity and amount", parts[0])
+				continue
+			}
+			date, err := ParseDate(parts[1])
+			if err != nil {
+				p.report(block.Pos(n, offsets[1]), SeverityError, "bad date: %s", parts[1])
+				continue
+			}
+			currency, amount, err := parseCurrencyAmount(parts[3])
+			if err != nil {
+				p.report(block.Pos(n, offsets[3]), SeverityError, "bad amount: %s", parts[3])
+				continue
+			}
+			commodity := strings.Trim(parts[2], `"`)
+			if currency == commodity {
+				p.report(block.Pos(n, offsets[3]), SeverityError, "price of %s in itself", currency)
+				continue
+			}
+			price := &Price{
+				Date:      date,
+				Commodity: commodity,
+				Currency:  currency,
+				Amount:    amount,
+				Pos:       block.Pos(n, 0),
+			}
+			if replaced := p.ledger.Prices.add(price); replaced != nil {
+				p.report(price.Pos, SeverityWarning, "%s of %s at %s already defined at %s", parts[0], price.Commodity, parts[1], replaced.Pos)
+			}
+
+		}
+	}
+}
+
+// checkDeclaration checks a posting against the open and close directives of its account
+func (p *parser) checkDeclaration(entry *Entry, date time.Time) {
+	severity := SeverityWarning
+	if p.Strict {
+		severity = SeverityError
+	}
+	decl := entry.Account.Declaration()
+	if decl == nil {
+		if p.Strict {
+			p.report(entry.Pos, SeverityError, "undeclared account: %s", strings.Join(entry.Account.Path(), "："))
+		}
+		return
+	}
+	if date.Before(decl.OpenDate) {
+		p.report(
+			entry.Pos, severity, "posting before account opened: %s opened at %s",
+			strings.Join(decl.Path(), "："), decl.OpenDate.Format("2006-01-02"),
+		)
+	}
+	if !decl.CloseDate.IsZero() && date.After(decl.CloseDate) {
+		p.report(
+			entry.Pos, severity, "posting to closed account: %s closed at %s",
+			strings.Join(decl.Path(), "："), decl.CloseDate.Format("2006-01-02"),
+		)
+	}
+	if len(decl.Currencies) > 0 {
+		allowed := false
+		for _, currency := range decl.Currencies {
+			if currency == entry.Currency {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			p.report(
+				entry.Pos, severity, "currency %s not allowed in %s",
+				entry.Currency, strings.Join(decl.Path(), "："),
+			)
+		}
+	}
+}
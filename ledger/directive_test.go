@@ -0,0 +1,147 @@
+package ledger
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestInclude(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for name, content := range map[string]string{
+		"main.ledger":    "include 2020/*.ledger\n\n2020-01-01 工资\n资产：现金 ￥100\n收入：工资 ￥-100\n",
+		"2020/01.ledger": "include ../main.ledger\n\n2020-01-02 午饭\n支出：饮食 ￥10\n资产：现金 ￥-10\n",
+		"2020/02.ledger": "include 02.ledger\n\n2020-02-02 晚饭\n支出：饮食 ￥20\n资产：现金 ￥-20\n",
+		"2020/other.txt": "not a ledger\n",
+		"missing.ledger": "include nothing/*.ledger\n",
+		"badglob.ledger": "include [\n",
+	} {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// included files are relative to the including file, and each file is loaded once
+	l, err := ParseFile(filepath.Join(dir, "main.ledger"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(l.Files) != 3 {
+		t.Fatalf("expecting 3 files, got %d", len(l.Files))
+	}
+	if len(l.Transactions) != 3 {
+		t.Fatalf("expecting 3 transactions, got %d", len(l.Transactions))
+	}
+	if balance := findAccount(l.Root, SplitAccount("资产：现金")).Balances["￥"].RatString(); balance != "70" {
+		t.Fatalf("got %s", balance)
+	}
+
+	for _, c := range []struct {
+		name       string
+		diagnostic string
+	}{
+		{"missing.ledger", "missing.ledger:1:9: error: no file matches nothing/*.ledger"},
+		{"badglob.ledger", "badglob.ledger:1:9: error: bad include pattern: ["},
+	} {
+		path := filepath.Join(dir, c.name)
+		l, err := ParseFile(path)
+		if err == nil {
+			t.Fatalf("%s: expecting error", c.name)
+		}
+		if len(l.Diagnostics) != 1 || l.Diagnostics[0].Error() != filepath.Join(dir, c.diagnostic) {
+			t.Fatalf("%s: got %v", c.name, l.Diagnostics)
+		}
+	}
+}
+
+func TestOpenClose(t *testing.T) {
+	const directives = "open 2020-01-01 资产：现金 ￥\nopen 2020-01-01 收入\nclose 2020-06-30 资产：现金\n\n"
+	for _, c := range []struct {
+		name        string
+		strict      bool
+		content     string
+		diagnostics []string
+	}{
+		{
+			"ok",
+			false,
+			"2020-01-02 a\n资产：现金 ￥1\n收入：工资 ￥-1\n",
+			nil,
+		},
+		{
+			"undeclared",
+			false,
+			"2020-01-02 a\n资产：银行 ￥1\n收入：工资 ￥-1\n",
+			nil,
+		},
+		{
+			"undeclared strict",
+			true,
+			"2020-01-02 a\n资产：银行 ￥1\n收入：工资 ￥-1\n",
+			[]string{"6:1: error: undeclared account: 资产：银行"},
+		},
+		{
+			"before opened",
+			false,
+			"2019-12-31 a\n资产：现金 ￥1\n收入：工资 ￥-1\n",
+			[]string{
+				"6:1: warning: posting before account opened: 资产：现金 opened at 2020-01-01",
+				"7:1: warning: posting before account opened: 收入 opened at 2020-01-01",
+			},
+		},
+		{
+			"after closed",
+			true,
+			"2020-07-01 a\n资产：现金 ￥1\n收入：工资 ￥-1\n",
+			[]string{"6:1: error: posting to closed account: 资产：现金 closed at 2020-06-30"},
+		},
+		{
+			"currency not allowed",
+			false,
+			"2020-01-02 a\n资产：现金 $1\n收入：工资 $-1\n",
+			[]string{"6:1: warning: currency $ not allowed in 资产：现金"},
+		},
+		{
+			"opened twice",
+			false,
+			"open 2020-02-01 资产：现金\n",
+			[]string{"5:17: error: account already opened: 资产：现金"},
+		},
+		{
+			"closed twice",
+			false,
+			"close 2020-07-01 资产：现金\n",
+			[]string{"5:18: error: account already closed: 资产：现金"},
+		},
+		{
+			"closed before opened",
+			false,
+			"open 2020-01-01 负债\nclose 2019-01-01 负债\n",
+			[]string{"6:7: error: account closed before opened: 负债"},
+		},
+	} {
+		l, err := Parser{
+			Strict: c.strict,
+		}.Parse(strings.NewReader(directives + c.content))
+		if l == nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		var diagnostics []string
+		for _, diag := range l.Diagnostics {
+			diagnostics = append(diagnostics, diag.Error())
+		}
+		if strings.Join(diagnostics, "\n") != strings.Join(c.diagnostics, "\n") {
+			t.Errorf("%s: expecting\n%s\ngot\n%s", c.name, strings.Join(c.diagnostics, "\n"), strings.Join(diagnostics, "\n"))
+		}
+	}
+}
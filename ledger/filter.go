@@ -0,0 +1,160 @@
+package ledger
+
+import (
+	"math/big"
+	"time"
+)
+
+// Filter selects entries of a ledger. The zero value selects all entries
+type Filter struct {
+	// Begin is the inclusive lower bound of entry time, zero for no bound
+	Begin time.Time
+	// End is the exclusive upper bound of entry time, zero for no bound
+	End time.Time
+	// Accounts selects entries of accounts matching any of the paths, or of their subaccounts. Nil selects all accounts
+	Accounts [][]string
+	// Metadata selects entries having all the keys with the values, in the metadata of the entry or its transaction.
+	// An empty value matches any value of the key
+	Metadata map[string]string
+	// Tags selects entries having any of the tags, given without <>. Nil selects all entries
+	Tags []string
+}
+
+// SplitAccount splits an account name like 资产：银行 into path
+func SplitAccount(name string) []string {
+	return accountSeparatePattern.Split(name, -1)
+}
+
+// Match reports whether entry of transaction is selected by f
+func (f Filter) Match(transaction *Transaction, entry *Entry) bool {
+	if !f.Begin.IsZero() && entry.Time.Before(f.Begin) {
+		return false
+	}
+	if !f.End.IsZero() && !entry.Time.Before(f.End) {
+		return false
+	}
+	if len(f.Accounts) > 0 {
+		matched := false
+	loop:
+		for _, path := range f.Accounts {
+			for account := entry.Account; account.Parent != nil; account = account.Parent {
+				if len(account.Path()) == len(path) && account.MatchPath(path) {
+					matched = true
+					break loop
+				}
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	if len(f.Tags) > 0 {
+		matched := false
+		for _, tag := range f.Tags {
+			if entry.Tags["<"+tag+">"] {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	for key, value := range f.Metadata {
+		v, ok := transaction.Lookup(entry, key)
+		if !ok || value != "" && v != value {
+			return false
+		}
+	}
+	return true
+}
+
+// Filter returns a ledger with the entries selected by f.
+// The account tree is copied with balances of the selected entries, transactions without selected entries are dropped.
+// Files, blocks, diagnostics and prices are shared with l
+func (l *Ledger) Filter(f Filter) *Ledger {
+	accounts := make(map[*Account]*Account)
+	var clone func(account *Account, parent *Account) *Account
+	clone = func(account *Account, parent *Account) *Account {
+		ret := newAccount(account.Name, parent)
+		ret.OpenDate = account.OpenDate
+		ret.CloseDate = account.CloseDate
+		ret.Currencies = account.Currencies
+		accounts[account] = ret
+		for name, sub := range account.Subs {
+			ret.Subs[name] = clone(sub, ret)
+		}
+		return ret
+	}
+
+	ret := &Ledger{
+		Root:         clone(l.Root, nil),
+		Blocks:       l.Blocks,
+		Diagnostics:  l.Diagnostics,
+		Files:        l.Files,
+		Prices:       l.Prices,
+		AccountTypes: l.AccountTypes,
+	}
+
+	entries := make(map[*Entry]*Entry)
+	transactions := make(map[*Transaction]*Transaction)
+	for _, transaction := range l.Transactions {
+		var selected []*Entry
+		for _, entry := range transaction.Entries {
+			if !f.Match(transaction, entry) {
+				continue
+			}
+			copied := *entry
+			copied.Account = accounts[entry.Account]
+			entries[entry] = &copied
+			selected = append(selected, &copied)
+			for account := copied.Account; account != nil; account = account.Parent {
+				balance, ok := account.Balances[entry.Currency]
+				if !ok {
+					balance = new(big.Rat)
+					account.Balances[entry.Currency] = balance
+				}
+				balance.Add(balance, entry.Amount)
+			}
+			if copied.Account.TimeFrom.IsZero() || copied.Time.Before(copied.Account.TimeFrom) {
+				copied.Account.TimeFrom = copied.Time
+			}
+		}
+		if len(selected) == 0 {
+			continue
+		}
+		t := *transaction
+		t.Entries = selected
+		t.Assertions = nil
+		transactions[transaction] = &t
+		ret.Transactions = append(ret.Transactions, &t)
+	}
+
+	lots := make(map[*Lot]*Lot)
+	for _, lot := range l.Lots {
+		entry, ok := entries[lot.Entry]
+		if !ok {
+			continue
+		}
+		copied := *lot
+		copied.Account = entry.Account
+		copied.Entry = entry
+		lots[lot] = &copied
+		ret.Lots = append(ret.Lots, &copied)
+	}
+	for _, gain := range l.Gains {
+		entry, ok := entries[gain.Entry]
+		if !ok {
+			continue
+		}
+		copied := *gain
+		copied.Entry = entry
+		copied.Transaction = transactions[gain.Transaction]
+		if lot, ok := lots[gain.Lot]; ok {
+			copied.Lot = lot
+		}
+		ret.Gains = append(ret.Gains, &copied)
+	}
+
+	return ret
+}
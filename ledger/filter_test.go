@@ -0,0 +1,151 @@
+package ledger
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+const filterLedger = `2020-01-01 工资
+资产：工行 ￥1000
+收入：工资 ￥-1000
+
+2020-01-15 午饭
+  payee: 食堂
+支出：饮食 ￥20 <工作>
+资产：现金 ￥-20
+
+2020-02-01 电池
+支出：数码 ￥50 <家>
+  receipt: a.pdf
+资产：工行 ￥-50
+`
+
+// filterResult returns the balances of accounts in the filtered ledger and the descriptions of the selected transactions
+func filterResult(t *testing.T, f Filter) (balances map[string]string, transactions []string) {
+	l, err := Parse(strings.NewReader(filterLedger))
+	if err != nil {
+		t.Fatal(err)
+	}
+	filtered := l.Filter(f)
+	balances = make(map[string]string)
+	for _, path := range []string{"资产", "资产：工行", "资产：现金", "支出", "收入"} {
+		if account := findAccount(filtered.Root, SplitAccount(path)); account != nil {
+			if balance, ok := account.Balances["￥"]; ok {
+				balances[path] = balance.RatString()
+			}
+		}
+	}
+	for _, transaction := range filtered.Transactions {
+		transactions = append(transactions, transaction.Description)
+	}
+	return
+}
+
+func TestFilter(t *testing.T) {
+	date := func(s string) time.Time {
+		t, err := ParseDate(s)
+		if err != nil {
+			panic(err)
+		}
+		return t
+	}
+	for _, c := range []struct {
+		name         string
+		filter       Filter
+		balances     map[string]string
+		transactions string
+	}{
+		{
+			"all",
+			Filter{},
+			map[string]string{"资产": "930", "资产：工行": "950", "资产：现金": "-20", "支出": "70", "收入": "-1000"},
+			"工资 午饭 电池",
+		},
+		{
+			"begin",
+			Filter{Begin: date("2020-01-15")},
+			map[string]string{"资产": "-70", "资产：工行": "-50", "资产：现金": "-20", "支出": "70"},
+			"午饭 电池",
+		},
+		{
+			"end exclusive",
+			Filter{End: date("2020-01-15")},
+			map[string]string{"资产": "1000", "资产：工行": "1000", "收入": "-1000"},
+			"工资",
+		},
+		{
+			"account prefix",
+			Filter{Accounts: [][]string{SplitAccount("资产：工行")}},
+			map[string]string{"资产": "950", "资产：工行": "950"},
+			"工资 电池",
+		},
+		{
+			"accounts",
+			Filter{Accounts: [][]string{SplitAccount("支出"), SplitAccount("收入")}},
+			map[string]string{"支出": "70", "收入": "-1000"},
+			"工资 午饭 电池",
+		},
+		{
+			"top account does not match subaccount name",
+			Filter{Accounts: [][]string{SplitAccount("工行")}},
+			map[string]string{},
+			"",
+		},
+	} {
+		balances, transactions := filterResult(t, c.filter)
+		if len(balances) != len(c.balances) {
+			t.Errorf("%s: expecting %v, got %v", c.name, c.balances, balances)
+		}
+		for path, balance := range c.balances {
+			if balances[path] != balance {
+				t.Errorf("%s: %s: expecting %s, got %s", c.name, path, balance, balances[path])
+			}
+		}
+		if got := strings.Join(transactions, " "); got != c.transactions {
+			t.Errorf("%s: expecting transactions %s, got %s", c.name, c.transactions, got)
+		}
+	}
+}
+
+func TestFilterMetadata(t *testing.T) {
+	for _, c := range []struct {
+		metadata     map[string]string
+		transactions string
+	}{
+		{map[string]string{"payee": "食堂"}, "午饭"},
+		{map[string]string{"payee": "饭馆"}, ""},
+		{map[string]string{"receipt": ""}, "电池"},
+		{map[string]string{"receipt": "", "payee": ""}, ""},
+	} {
+		_, transactions := filterResult(t, Filter{Metadata: c.metadata})
+		if got := strings.Join(transactions, " "); got != c.transactions {
+			t.Errorf("%v: expecting transactions %s, got %s", c.metadata, c.transactions, got)
+		}
+	}
+}
+
+func TestFilterTags(t *testing.T) {
+	for _, c := range []struct {
+		tags         []string
+		balances     map[string]string
+		transactions string
+	}{
+		{[]string{"工作"}, map[string]string{"支出": "20"}, "午饭"},
+		{[]string{"工作", "家"}, map[string]string{"支出": "70"}, "午饭 电池"},
+		{[]string{"旅行"}, map[string]string{}, ""},
+	} {
+		balances, transactions := filterResult(t, Filter{Tags: c.tags})
+		if len(balances) != len(c.balances) {
+			t.Errorf("%v: expecting %v, got %v", c.tags, c.balances, balances)
+		}
+		for path, balance := range c.balances {
+			if balances[path] != balance {
+				t.Errorf("%v: %s: expecting %s, got %s", c.tags, path, balance, balances[path])
+			}
+		}
+		if got := strings.Join(transactions, " "); got != c.transactions {
+			t.Errorf("%v: expecting transactions %s, got %s", c.tags, c.transactions, got)
+		}
+	}
+}
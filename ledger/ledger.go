@@ -0,0 +1,103 @@
+package ledger
+
+import (
+	"io"
+	"io/ioutil"
+	"regexp"
+)
+
+var (
+	accountSeparatePattern = regexp.MustCompile(`：|:`)
+	sharePricePattern      = regexp.MustCompile(`[0-9]+\.[0-9]{3}`)
+	blanksPattern          = regexp.MustCompile(`\s+`)
+	inlineDatePattern      = regexp.MustCompile(`@[0-9]{4}[/.-][0-9]{2}[/.-][0-9]{2}`)
+	commentLinePattern     = regexp.MustCompile(`^\s*(#|//)`)
+	yearMonthPattern       = regexp.MustCompile(`[0-9]{4}`)
+	entryTagPattern        = regexp.MustCompile(`<[^>]+>`)
+	metadataLinePattern    = regexp.MustCompile(`^([^\s:：]+)[:：]\s+(.*)$`)
+	// payeeSeparatorPattern separates payee and description in headers, | without blanks is part of description
+	payeeSeparatorPattern = regexp.MustCompile(`\s+\|\s+`)
+)
+
+// Ledger is the result of parsing ledger files
+type Ledger struct {
+	Root         *Account
+	Transactions []*Transaction
+	// Blocks of all files, sorted by HeaderDate
+	Blocks      []*Block
+	Diagnostics Diagnostics
+	// Files holds all loaded files, including the included ones, in loading order
+	Files []*File
+	// Prices is the price history defined by price and rate directives
+	Prices Prices
+	// Lots holds all acquired lots in acquisition order
+	Lots []*Lot
+	// Gains holds realized gains of reduced lots in posting order
+	Gains []*RealizedGain
+	// AccountTypes maps root account names to types
+	AccountTypes AccountTypes
+}
+
+// Parser holds parsing options. The zero value parses with default options
+type Parser struct {
+	// Strict rejects postings to undeclared accounts, and reports violations of declarations as errors instead of warnings
+	Strict bool
+	// AccountTypes maps root account names to types, DefaultAccountTypes is used if nil
+	AccountTypes AccountTypes
+	// PayeeAliases maps payee names to the names they are merged into
+	PayeeAliases map[string]string
+}
+
+// Parse reads and parses a ledger.
+// Included files are resolved relative to the working directory.
+// Problems are collected in Ledger.Diagnostics; if any of them is an error,
+// the ledger is returned along with the Diagnostics as error
+func Parse(r io.Reader) (*Ledger, error) {
+	return Parser{}.Parse(r)
+}
+
+// ParseFile parses the ledger file at path, diagnostics refer to the path
+func ParseFile(path string) (*Ledger, error) {
+	return Parser{}.ParseFiles(path)
+}
+
+// ParseFiles parses and merges the ledger files at paths
+func ParseFiles(paths ...string) (*Ledger, error) {
+	return Parser{}.ParseFiles(paths...)
+}
+
+// Parse is like the package-level Parse, with options of p
+func (p Parser) Parse(r io.Reader) (*Ledger, error) {
+	contentBytes, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, me(err, "read ledger")
+	}
+	state := newParser(p)
+	state.load("", contentBytes)
+	return state.finish()
+}
+
+// ParseFiles is like the package-level ParseFiles, with options of p
+func (p Parser) ParseFiles(paths ...string) (*Ledger, error) {
+	state := newParser(p)
+	for _, path := range paths {
+		if err := state.loadFile(path); err != nil {
+			return nil, me(err, "read ledger")
+		}
+	}
+	return state.finish()
+}
+
+// splitFields splits line into at most n blank-separated fields, or all fields if n < 0,
+// returning the byte offset of each field
+func splitFields(line string, n int) (fields []string, offsets []int) {
+	start := 0
+	for _, loc := range blanksPattern.FindAllStringIndex(line, n-1) {
+		fields = append(fields, line[start:loc[0]])
+		offsets = append(offsets, start)
+		start = loc[1]
+	}
+	fields = append(fields, line[start:])
+	offsets = append(offsets, start)
+	return
+}
@@ -0,0 +1,241 @@
+package ledger
+
+import (
+	"math/big"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Booking is the method to choose lots reduced by a posting
+type Booking string
+
+const (
+	FIFO Booking = "fifo"
+	LIFO Booking = "lifo"
+)
+
+// LotSpec is the lot annotation of a posting, like {￥1.234,2020-01-02,label}.
+// For postings acquiring a commodity, it specifies the per-unit cost and the lot.
+// For postings reducing a commodity, it selects lots to match, or the booking method like {lifo}
+type LotSpec struct {
+	// Cost is the per-unit cost, nil if not specified
+	Cost         *big.Rat
+	CostCurrency string
+	Date         time.Time
+	Label        string
+	Booking      Booking
+}
+
+// Lot is a quantity of a commodity held by an account, acquired at a cost
+type Lot struct {
+	Account      *Account
+	Commodity    string
+	Cost         *big.Rat
+	CostCurrency string
+	Date         time.Time
+	Label        string
+	// Quantity is the remaining quantity, zero if the lot is fully reduced
+	Quantity *big.Rat
+	// Entry is the acquiring posting
+	Entry *Entry
+}
+
+// LotMatch is a part of a lot reduced by a posting
+type LotMatch struct {
+	Lot      *Lot
+	Quantity *big.Rat
+}
+
+// RealizedGain is the gain of a reduced part of a lot, in the cost currency of the lot
+type RealizedGain struct {
+	Transaction *Transaction
+	Entry       *Entry
+	Lot         *Lot
+	Quantity    *big.Rat
+	Currency    string
+	Cost        *big.Rat
+	Proceeds    *big.Rat
+	Gain        *big.Rat
+}
+
+// parseLotSpec parses the content of a lot annotation, fields are separated by commas.
+// A field is a booking method, a date, a cost, or a label. Labels that look like an amount must be double-quoted
+func parseLotSpec(str string) (*LotSpec, error) {
+	spec := new(LotSpec)
+	for _, field := range strings.Split(str, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		switch Booking(strings.ToLower(field)) {
+		case FIFO, LIFO:
+			spec.Booking = Booking(strings.ToLower(field))
+			continue
+		}
+		if date, err := ParseDate(field); err == nil {
+			spec.Date = date
+			continue
+		}
+		if currency, amount, err := parseCurrencyAmount(field); err == nil {
+			if amount.Sign() < 0 {
+				return nil, me(nil, "negative cost: %s", field)
+			}
+			spec.Cost = amount
+			spec.CostCurrency = currency
+			continue
+		}
+		spec.Label = strings.Trim(field, `"`)
+	}
+	return spec, nil
+}
+
+// bookLots acquires or reduces lots for a posting with lot annotation
+func (p *parser) bookLots(transaction *Transaction, entry *Entry) {
+	spec := entry.Lot
+
+	if entry.Amount.Sign() > 0 {
+		// acquire
+		if spec.Cost == nil {
+			p.report(entry.Pos, SeverityError, "missing lot cost")
+			return
+		}
+		date := spec.Date
+		if date.IsZero() {
+			date = transaction.Date
+		}
+		p.ledger.Lots = append(p.ledger.Lots, &Lot{
+			Account:      entry.Account,
+			Commodity:    entry.Currency,
+			Cost:         spec.Cost,
+			CostCurrency: spec.CostCurrency,
+			Date:         date,
+			Label:        spec.Label,
+			Quantity:     new(big.Rat).Set(entry.Amount),
+			Entry:        entry,
+		})
+		return
+	}
+
+	// reduce
+	var candidates []*Lot
+	for _, lot := range p.ledger.Lots {
+		if lot.Account != entry.Account ||
+			lot.Commodity != entry.Currency ||
+			lot.Quantity.Sign() == 0 {
+			continue
+		}
+		if spec.Cost != nil && (lot.Cost.Cmp(spec.Cost) != 0 || lot.CostCurrency != spec.CostCurrency) {
+			continue
+		}
+		if !spec.Date.IsZero() && !lot.Date.Equal(spec.Date) {
+			continue
+		}
+		if spec.Label != "" && lot.Label != spec.Label {
+			continue
+		}
+		candidates = append(candidates, lot)
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].Date.Before(candidates[j].Date)
+	})
+	if spec.Booking == LIFO {
+		for i, j := 0, len(candidates)-1; i < j; i, j = i+1, j-1 {
+			candidates[i], candidates[j] = candidates[j], candidates[i]
+		}
+	}
+
+	remain := new(big.Rat).Neg(entry.Amount)
+	for _, lot := range candidates {
+		if remain.Sign() == 0 {
+			break
+		}
+		quantity := new(big.Rat).Set(lot.Quantity)
+		if quantity.Cmp(remain) > 0 {
+			quantity.Set(remain)
+		}
+		lot.Quantity.Sub(lot.Quantity, quantity)
+		remain.Sub(remain, quantity)
+		entry.Matches = append(entry.Matches, &LotMatch{
+			Lot:      lot,
+			Quantity: quantity,
+		})
+	}
+	if remain.Sign() > 0 {
+		p.report(
+			entry.Pos, SeverityError, "not enough lots of %s to reduce: %s short",
+			entry.Currency, remain.FloatString(2),
+		)
+	}
+
+	// realized gains
+	for _, match := range entry.Matches {
+		lot := match.Lot
+		var rate *big.Rat
+		if entry.Price != nil {
+			r, ok := p.ledger.Prices.Rate(entry.PriceCurrency, lot.CostCurrency, transaction.Date)
+			if !ok {
+				p.report(entry.Pos, SeverityWarning, "no rate from %s to %s, realized gain unknown", entry.PriceCurrency, lot.CostCurrency)
+				continue
+			}
+			rate = r.Mul(r, entry.Price)
+		} else {
+			r, ok := p.ledger.Prices.Rate(entry.Currency, lot.CostCurrency, transaction.Date)
+			if !ok {
+				p.report(entry.Pos, SeverityWarning, "no price of %s in %s, realized gain unknown", entry.Currency, lot.CostCurrency)
+				continue
+			}
+			rate = r
+		}
+		cost := new(big.Rat).Mul(match.Quantity, lot.Cost)
+		proceeds := new(big.Rat).Mul(match.Quantity, rate)
+		p.ledger.Gains = append(p.ledger.Gains, &RealizedGain{
+			Transaction: transaction,
+			Entry:       entry,
+			Lot:         lot,
+			Quantity:    match.Quantity,
+			Currency:    lot.CostCurrency,
+			Cost:        cost,
+			Proceeds:    proceeds,
+			Gain:        new(big.Rat).Sub(proceeds, cost),
+		})
+	}
+}
+
+// costAmount is an amount in a currency
+type costAmount struct {
+	currency string
+	amount   *big.Rat
+}
+
+// lotCosts returns the cost basis of a posting with lot annotation, negative for reductions
+func (e *Entry) lotCosts() (ret []costAmount) {
+	if e.Lot == nil {
+		return nil
+	}
+	if e.Amount.Sign() > 0 {
+		if e.Lot.Cost == nil {
+			return nil
+		}
+		return []costAmount{
+			{e.Lot.CostCurrency, new(big.Rat).Mul(e.Amount, e.Lot.Cost)},
+		}
+	}
+	for _, match := range e.Matches {
+		cost := new(big.Rat).Mul(match.Quantity, match.Lot.Cost)
+		ret = append(ret, costAmount{match.Lot.CostCurrency, cost.Neg(cost)})
+	}
+	return
+}
+
+// weight returns the amount of the posting counted in balance check
+func (e *Entry) weight() *big.Rat {
+	if e.Lot == nil {
+		return e.Amount
+	}
+	sum := new(big.Rat)
+	for _, cost := range e.lotCosts() {
+		sum.Add(sum, cost.amount)
+	}
+	return sum
+}
@@ -0,0 +1,133 @@
+package ledger
+
+import (
+	"math/big"
+	"strings"
+	"testing"
+)
+
+func TestBookLots(t *testing.T) {
+	const buys = `2020-01-01 init
+资产：工行 $10000
+权益 $-10000
+
+2020-01-02 buy
+资产：券商 AAPL10{$100}
+资产：工行 $-1000
+
+2020-02-02 buy
+资产：券商 AAPL10{$120,"l1"}
+资产：工行 $-1200
+
+`
+	type gain struct {
+		quantity string
+		cost     string
+		gain     string
+	}
+	for _, c := range []struct {
+		name  string
+		sell  string
+		gains []gain
+		// remaining quantities of the two lots
+		remaining []string
+	}{
+		{
+			"fifo",
+			"2020-03-02 sell\n资产：券商 AAPL-15{}@$150\n资产：工行 $2250\n收入：投资收益 $-650\n",
+			[]gain{
+				{"10", "1000", "500"},
+				{"5", "600", "150"},
+			},
+			[]string{"0", "5"},
+		},
+		{
+			"lifo",
+			"2020-03-02 sell\n资产：券商 AAPL-15{lifo}@$150\n资产：工行 $2250\n收入：投资收益 $-550\n",
+			[]gain{
+				{"10", "1200", "300"},
+				{"5", "500", "250"},
+			},
+			[]string{"5", "0"},
+		},
+		{
+			"label",
+			"2020-03-02 sell\n资产：券商 AAPL-5{\"l1\"}@$130\n资产：工行 $650\n收入：投资收益 $-50\n",
+			[]gain{
+				{"5", "600", "50"},
+			},
+			[]string{"10", "5"},
+		},
+		{
+			"cost",
+			"2020-03-02 sell\n资产：券商 AAPL-5{$120}@$130\n资产：工行 $650\n收入：投资收益 $-50\n",
+			[]gain{
+				{"5", "600", "50"},
+			},
+			[]string{"10", "5"},
+		},
+	} {
+		l, err := Parse(strings.NewReader(buys + c.sell))
+		if err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		if len(l.Gains) != len(c.gains) {
+			t.Fatalf("%s: expecting %d gains, got %d", c.name, len(c.gains), len(l.Gains))
+		}
+		rat := func(s string) *big.Rat {
+			r, _ := new(big.Rat).SetString(s)
+			return r
+		}
+		for i, expected := range c.gains {
+			got := l.Gains[i]
+			if got.Quantity.Cmp(rat(expected.quantity)) != 0 ||
+				got.Cost.Cmp(rat(expected.cost)) != 0 ||
+				got.Gain.Cmp(rat(expected.gain)) != 0 {
+				t.Errorf("%s: gain %d: expecting %v, got quantity %s cost %s gain %s",
+					c.name, i, expected, got.Quantity.RatString(), got.Cost.RatString(), got.Gain.RatString())
+			}
+		}
+		if len(l.Lots) != len(c.remaining) {
+			t.Fatalf("%s: expecting %d lots, got %d", c.name, len(c.remaining), len(l.Lots))
+		}
+		for i, quantity := range c.remaining {
+			if l.Lots[i].Quantity.Cmp(rat(quantity)) != 0 {
+				t.Errorf("%s: lot %d: expecting %s, got %s", c.name, i, quantity, l.Lots[i].Quantity.RatString())
+			}
+		}
+	}
+}
+
+func TestBookLotsInsufficient(t *testing.T) {
+	l, err := Parse(strings.NewReader(`2020-01-02 buy
+资产：券商 AAPL10{$100}
+资产：工行 $-1000
+
+2020-03-02 sell
+资产：券商 AAPL-15{}@$150
+资产：工行 $2250
+收入：投资收益 $-750
+`))
+	if err == nil || !strings.Contains(err.Error(), "6:1: error: not enough lots of AAPL to reduce: 5.00 short") {
+		t.Fatalf("expecting error of insufficient lots, got %v, %d gains", err, len(l.Gains))
+	}
+}
+
+func TestAnnotationAfterBlanks(t *testing.T) {
+	for _, c := range []struct {
+		line    string
+		message string
+	}{
+		{"资产：基金 FUND10 {￥1.5}", "2:24: error: lot annotation must follow the amount without blanks: {￥1.5}"},
+		{"资产：基金 FUND10 @￥1.5", "2:24: error: price annotation must follow the amount without blanks: @￥1.5"},
+	} {
+		_, err := Parse(strings.NewReader("2020-01-01 a\n" + c.line + "\n资产：现金 ￥-15\n"))
+		if err == nil || err.Error() != c.message {
+			t.Errorf("%s: expecting %s, got %v", c.line, c.message, err)
+		}
+	}
+	// inline dates are not annotations
+	if _, err := Parse(strings.NewReader("2020-01-01 a\n资产：现金 ￥1 @2020-01-05 x\n收入：工资 ￥-1\n")); err != nil {
+		t.Fatal(err)
+	}
+}
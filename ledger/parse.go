@@ -0,0 +1,341 @@
+package ledger
+
+import (
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode"
+)
+
+type parser struct {
+	Parser
+	ledger *Ledger
+	loaded map[string]bool
+}
+
+func newParser(options Parser) *parser {
+	if options.AccountTypes == nil {
+		options.AccountTypes = DefaultAccountTypes
+	}
+	return &parser{
+		Parser: options,
+		ledger: &Ledger{
+			Root:         newAccount("root", nil),
+			Prices:       make(Prices),
+			AccountTypes: options.AccountTypes,
+		},
+		loaded: make(map[string]bool),
+	}
+}
+
+// loadFile loads the file at path unless it is already loaded
+func (p *parser) loadFile(path string) error {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	if p.loaded[abs] {
+		return nil
+	}
+	p.loaded[abs] = true
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	p.load(path, content)
+	return nil
+}
+
+// load splits content into blocks and handles directives that affect loading
+func (p *parser) load(name string, content []byte) {
+	file := parseFile(name, string(content))
+	p.ledger.Files = append(p.ledger.Files, file)
+	for _, block := range file.Blocks {
+		p.ledger.Blocks = append(p.ledger.Blocks, block)
+		if block.Kind == DirectiveBlock {
+			p.loadDirectives(block)
+		}
+	}
+}
+
+// finish parses loaded blocks
+func (p *parser) finish() (*Ledger, error) {
+	ledger := p.ledger
+	sortBlocks(ledger.Blocks)
+	for _, block := range ledger.Blocks {
+		if block.Kind == DirectiveBlock {
+			p.parseDirectives(block)
+		}
+	}
+	for _, block := range ledger.Blocks {
+		if block.Kind != TransactionBlock {
+			continue
+		}
+		if transaction := p.parseBlock(block); transaction != nil {
+			ledger.Transactions = append(ledger.Transactions, transaction)
+		}
+	}
+	// blocks are processed in date order, diagnostics are printed in file order
+	ledger.Diagnostics.Sort()
+	if ledger.Diagnostics.HasError() {
+		return ledger, ledger.Diagnostics
+	}
+	return ledger, nil
+}
+
+func (p *parser) report(pos Position, severity Severity, format string, args ...interface{}) {
+	p.ledger.Diagnostics = append(p.ledger.Diagnostics, &Diagnostic{
+		Pos:      pos,
+		Severity: severity,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+// parseBlock parses a transaction block, returns nil if the block is bad
+func (p *parser) parseBlock(block *Block) *Transaction {
+	transaction := new(Transaction)
+
+	bad := false
+	reportError := func(pos Position, format string, args ...interface{}) {
+		p.report(pos, SeverityError, format, args...)
+		bad = true
+	}
+
+	// header
+	h := block.Header()
+	header := block.Contents[h]
+	parts, offsets := splitFields(header, 2)
+	if len(parts) != 2 {
+		reportError(block.Pos(h, 0), "bad header")
+		return nil
+	}
+	t, err := ParseDate(parts[0])
+	if err != nil {
+		reportError(block.Pos(h, offsets[0]), "bad date: %s", parts[0])
+		return nil
+	}
+	transaction.TimeFrom = t
+	transaction.TimeTo = t
+	transaction.Date = t
+	transaction.Description = parts[1]
+	if loc := payeeSeparatorPattern.FindStringIndex(parts[1]); loc != nil {
+		// payee | description
+		transaction.Payee = parts[1][:loc[0]]
+		transaction.Description = parts[1][loc[1]:]
+	}
+
+	// entries
+	metadata := &transaction.Metadata
+	for n := h + 1; n < len(block.Contents); n++ {
+		line := block.Contents[n]
+		if commentLinePattern.MatchString(line) {
+			continue
+		}
+		if strings.HasPrefix(line, "=") {
+			if assertion := p.parseAssertion(block, n, reportError); assertion != nil {
+				transaction.Assertions = append(transaction.Assertions, assertion)
+			}
+			continue
+		}
+		if key, value, ok := block.Metadata(n); ok {
+			// attached to the preceding entry, or the transaction
+			if *metadata == nil {
+				*metadata = make(Metadata)
+			}
+			if _, ok := (*metadata)[key]; ok {
+				reportError(block.Pos(n, 0), "duplicated metadata: %s", key)
+				continue
+			}
+			(*metadata)[key] = value
+			continue
+		}
+		entry := p.parseEntry(block, n, t, reportError)
+		if entry == nil {
+			// metadata of a bad entry is not attached to the transaction
+			metadata = new(Metadata)
+			continue
+		}
+		transaction.Entries = append(transaction.Entries, entry)
+		metadata = &entry.Metadata
+	}
+
+	if bad {
+		return nil
+	}
+
+	if transaction.Payee == "" {
+		transaction.Payee = transaction.Metadata["payee"]
+	}
+	if alias, ok := p.PayeeAliases[transaction.Payee]; ok {
+		transaction.Payee = alias
+	}
+
+	// balances are updated even if the checks below fail, to keep them consistent with the returned transaction
+
+	// book lots
+	for _, entry := range transaction.Entries {
+		if entry.Lot != nil {
+			p.bookLots(transaction, entry)
+		}
+	}
+
+	// check balance
+	sum := big.NewRat(0, 1)
+	for _, entry := range transaction.Entries {
+		sum.Add(sum, entry.weight())
+		// update account balance
+		account := entry.Account
+		for account != nil {
+			balance, ok := account.Balances[entry.Currency]
+			if !ok {
+				balance = big.NewRat(0, 1)
+				account.Balances[entry.Currency] = balance
+			}
+			balance.Add(balance, entry.Amount)
+			if sharePricePattern.MatchString(account.Name) {
+				isNegative := strings.HasPrefix(account.Parent.Name, "-") ||
+					strings.HasPrefix(account.Name, "-")
+				if !isNegative {
+					if balance.Sign() < 0 {
+						p.report(entry.Pos, SeverityError, "negative balance in stock share account: %s", account.Name)
+					}
+				} else {
+					if balance.Sign() > 0 {
+						p.report(entry.Pos, SeverityError, "positive balance in stock share account: %s", account.Name)
+					}
+				}
+			}
+			account = account.Parent
+		}
+	}
+	if sum.Cmp(zeroRat) != 0 {
+		p.report(block.Pos(h, 0), SeverityError, "not balanced: sum is %s", sum.FloatString(2))
+	}
+
+	// check assertions
+	for _, assertion := range transaction.Assertions {
+		actual := zeroRat
+		if account := findAccount(p.ledger.Root, assertion.Path); account != nil {
+			if balance, ok := account.Balances[assertion.Currency]; ok {
+				actual = balance
+			}
+		}
+		if actual.Cmp(assertion.Amount) != 0 {
+			diff := new(big.Rat).Sub(actual, assertion.Amount)
+			p.report(
+				assertion.Pos, SeverityError,
+				"balance assertion failed: %s expected %s, actual %s, difference %s",
+				strings.Join(assertion.Path, "："),
+				formatAmount(assertion.Currency, assertion.Amount),
+				formatAmount(assertion.Currency, actual),
+				formatAmount(assertion.Currency, diff),
+			)
+		}
+	}
+
+	return transaction
+}
+
+func (p *parser) parseEntry(
+	block *Block,
+	n int,
+	t time.Time,
+	reportError func(Position, string, ...interface{}),
+) *Entry {
+	line := block.Contents[n]
+	parts, offsets := splitFields(line, 3)
+	if len(parts) < 2 {
+		reportError(block.Pos(n, 0), "bad entry")
+		return nil
+	}
+	entry := &Entry{
+		Pos: block.Pos(n, 0),
+	}
+
+	accountStr := parts[0]
+	account := getAccount(p.ledger.Root, accountSeparatePattern.Split(accountStr, -1))
+	entry.Account = account
+
+	if err := parseEntryAmount(entry, parts[1]); err != nil {
+		reportError(block.Pos(n, offsets[1]), "bad amount: %s", parts[1])
+		return nil
+	}
+
+	if len(parts) > 2 {
+		entry.Description = parts[2]
+		// annotations are part of the amount field
+		if strings.HasPrefix(parts[2], "{") {
+			reportError(block.Pos(n, offsets[2]), "lot annotation must follow the amount without blanks: %s", parts[2])
+			return nil
+		}
+		if loc := inlineDatePattern.FindStringIndex(parts[2]); strings.HasPrefix(parts[2], "@") && (loc == nil || loc[0] != 0) {
+			reportError(block.Pos(n, offsets[2]), "price annotation must follow the amount without blanks: %s", parts[2])
+			return nil
+		}
+	}
+
+	entry.Tags = make(map[string]bool)
+	for _, tag := range entryTagPattern.FindAllString(entry.Description, -1) {
+		entry.Tags[tag] = true
+	}
+
+	var entryTime time.Time
+	var err error
+	if loc := inlineDatePattern.FindStringIndex(entry.Description); loc != nil {
+		entryTime, err = ParseDate(entry.Description[loc[0]+1 : loc[1]])
+		if err != nil {
+			reportError(block.Pos(n, offsets[2]+loc[0]), "bad date: %s", entry.Description[loc[0]:loc[1]])
+			return nil
+		}
+	} else if yearMonthPattern.MatchString(account.Name) && p.AccountTypes.Of(account) == LiabilityAccount {
+		entryTime, err = time.Parse("0601", account.Name)
+		if err != nil {
+			reportError(block.Pos(n, 0), "bad month: %s", account.Name)
+			return nil
+		}
+	} else {
+		entryTime = t
+	}
+	entry.Time = entryTime
+	entry.Year = entryTime.Year()
+	entry.Month = int(entryTime.Month())
+	entry.Day = entryTime.Day()
+
+	if account.TimeFrom.IsZero() || entryTime.Before(account.TimeFrom) {
+		account.TimeFrom = entryTime
+	}
+
+	p.checkDeclaration(entry, t)
+
+	return entry
+}
+
+// parseAssertion parses a balance assertion line: = <account> <amount> [description]
+func (p *parser) parseAssertion(
+	block *Block,
+	n int,
+	reportError func(Position, string, ...interface{}),
+) *Assertion {
+	line := block.Contents[n]
+	rest := strings.TrimLeftFunc(line[1:], unicode.IsSpace)
+	shift := len(line) - len(rest)
+	parts, offsets := splitFields(rest, 3)
+	if len(parts) < 2 {
+		reportError(block.Pos(n, 0), "bad assertion")
+		return nil
+	}
+	currency, amount, err := parseCurrencyAmount(parts[1])
+	if err != nil {
+		reportError(block.Pos(n, shift+offsets[1]), "bad amount: %s", parts[1])
+		return nil
+	}
+	return &Assertion{
+		Path:     accountSeparatePattern.Split(parts[0], -1),
+		Currency: currency,
+		Amount:   amount,
+		Pos:      block.Pos(n, 0),
+	}
+}
@@ -0,0 +1,142 @@
+package ledger
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParsePayee(t *testing.T) {
+	for _, c := range []struct {
+		header      string
+		payee       string
+		description string
+	}{
+		{"2020-01-01 京东 | 电池", "京东", "电池"},
+		{"2020-01-01 店  |  电池 | 两节", "店", "电池 | 两节"},
+		{"2020-01-01 x|y in desc", "", "x|y in desc"},
+		{"2020-01-01 |a", "", "|a"},
+		{"2020-01-01 a |b", "", "a |b"},
+		{"2020-01-01 电池", "", "电池"},
+		{"2020-01-01 JD | 电池", "京东", "电池"},
+	} {
+		l, err := Parser{
+			PayeeAliases: map[string]string{
+				"JD": "京东",
+			},
+		}.Parse(strings.NewReader(c.header + "\n资产：现金 ￥1\n收入：工资 ￥-1\n"))
+		if err != nil {
+			t.Fatalf("%s: %v", c.header, err)
+		}
+		transaction := l.Transactions[0]
+		if transaction.Payee != c.payee || transaction.Description != c.description {
+			t.Errorf("%s: expecting payee %q description %q, got %q %q",
+				c.header, c.payee, c.description, transaction.Payee, transaction.Description)
+		}
+	}
+}
+
+func TestParsePayeeMetadata(t *testing.T) {
+	l, err := Parse(strings.NewReader("2020-01-01 电池\n  payee: 京东\n资产：现金 ￥1\n收入：工资 ￥-1\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if payee := l.Transactions[0].Payee; payee != "京东" {
+		t.Fatalf("got %q", payee)
+	}
+}
+
+func TestParseDiagnostics(t *testing.T) {
+	for _, c := range []struct {
+		name        string
+		content     string
+		diagnostics []string
+	}{
+		{
+			"ok",
+			"2020-01-01 a\n资产：现金 ￥1\n收入：工资 ￥-1\n",
+			nil,
+		},
+		{
+			"bad amount",
+			"2020-01-01 a\n资产：现金 ￥1x\n收入：工资 ￥-1\n",
+			[]string{"2:17: error: bad amount: ￥1x"},
+		},
+		{
+			"not balanced",
+			"2020-01-01 a\n资产：现金 ￥1\n收入：工资 ￥-2\n",
+			[]string{"1:1: error: not balanced: sum is -1.00"},
+		},
+		{
+			"bad date",
+			"2020-01-01 a\n资产：现金 ￥1 @2020-13-01\n收入：工资 ￥-1\n",
+			[]string{"2:22: error: bad date: @2020-13-01"},
+		},
+		{
+			"bad header",
+			"2020-01-01\n资产：现金 ￥1\n收入：工资 ￥-1\n",
+			[]string{"1:1: error: bad header"},
+		},
+		{
+			"assertion",
+			"2020-01-01 a\n资产：现金 ￥1\n收入：工资 ￥-1\n= 资产：现金 ￥2\n",
+			[]string{"4:1: error: balance assertion failed: 资产：现金 expected ￥2.00, actual ￥1.00, difference ￥-1.00"},
+		},
+		{
+			"sorted by position",
+			"2020-02-01 a\n资产：现金 ￥1x\n收入：工资 ￥-1\n\n2020-01-01 b\n资产：现金 ￥1y\n收入：工资 ￥-1\n",
+			[]string{
+				"2:17: error: bad amount: ￥1x",
+				"6:17: error: bad amount: ￥1y",
+			},
+		},
+	} {
+		l, err := Parse(strings.NewReader(c.content))
+		if l == nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		var diagnostics []string
+		for _, diag := range l.Diagnostics {
+			diagnostics = append(diagnostics, diag.Error())
+		}
+		if strings.Join(diagnostics, "\n") != strings.Join(c.diagnostics, "\n") {
+			t.Errorf("%s: expecting\n%s\ngot\n%s", c.name, strings.Join(c.diagnostics, "\n"), strings.Join(diagnostics, "\n"))
+		}
+		if (err != nil) != (len(c.diagnostics) > 0) {
+			t.Errorf("%s: unexpected error: %v", c.name, err)
+		}
+	}
+}
+
+func TestParseMetadata(t *testing.T) {
+	l, err := Parse(strings.NewReader("2020-01-01 电池\n  receipt: a.pdf\n支出：数码 ￥1\n\tnote:   b c\n资产：现金 ￥-1\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	transaction := l.Transactions[0]
+	if v, ok := transaction.Lookup(transaction.Entries[0], "note"); !ok || v != "b c" {
+		t.Fatalf("got note %q", v)
+	}
+	if v, ok := transaction.Lookup(transaction.Entries[1], "receipt"); !ok || v != "a.pdf" {
+		t.Fatalf("got receipt %q", v)
+	}
+	if _, ok := transaction.Lookup(transaction.Entries[1], "note"); ok {
+		t.Fatal("note of the first entry attached to the second")
+	}
+	if len(transaction.Metadata) != 1 || len(transaction.Entries[0].Metadata) != 1 || transaction.Entries[1].Metadata != nil {
+		t.Fatalf("bad metadata: %v %v %v", transaction.Metadata, transaction.Entries[0].Metadata, transaction.Entries[1].Metadata)
+	}
+
+	for _, c := range []struct {
+		content string
+		message string
+	}{
+		// not indented, a mistyped posting
+		{"2020-01-01 a\n支出： 饮食 ￥20\n资产：现金 ￥-20\n", "2:11: error: bad amount: 饮食"},
+		{"2020-01-01 a\n  note: x\n  note: y\n资产：现金 ￥1\n收入：工资 ￥-1\n", "3:3: error: duplicated metadata: note"},
+	} {
+		_, err := Parse(strings.NewReader(c.content))
+		if err == nil || err.Error() != c.message {
+			t.Errorf("%q: expecting %s, got %v", c.content, c.message, err)
+		}
+	}
+}
@@ -0,0 +1,228 @@
+package ledger
+
+import (
+	"math/big"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Price is the value of one unit of a commodity in a currency at a date
+type Price struct {
+	Date      time.Time
+	Commodity string
+	Currency  string
+	Amount    *big.Rat
+	Pos       Position
+}
+
+// Prices is the price history, keyed by commodity, sorted by date
+type Prices map[string][]*Price
+
+func (p Prices) add(price *Price) (replaced *Price) {
+	history := p[price.Commodity]
+	i := sort.Search(len(history), func(i int) bool {
+		return !history[i].Date.Before(price.Date)
+	})
+	for j := i; j < len(history) && history[j].Date.Equal(price.Date); j++ {
+		if history[j].Currency == price.Currency {
+			replaced = history[j]
+			history[j] = price
+			return
+		}
+	}
+	history = append(history, nil)
+	copy(history[i+1:], history[i:])
+	history[i] = price
+	p[price.Commodity] = history
+	return
+}
+
+// History returns prices of commodity in currency, sorted by date
+func (p Prices) History(commodity string, currency string) (ret []*Price) {
+	for _, price := range p[commodity] {
+		if price.Currency == currency {
+			ret = append(ret, price)
+		}
+	}
+	return
+}
+
+// Lookup returns the latest price of commodity in currency at or before date, or nil if not found
+func (p Prices) Lookup(commodity string, currency string, date time.Time) *Price {
+	history := p[commodity]
+	for i := len(history) - 1; i >= 0; i-- {
+		price := history[i]
+		if price.Date.After(date) {
+			continue
+		}
+		if price.Currency == currency {
+			return price
+		}
+	}
+	return nil
+}
+
+// Valuation is the market value of accounts in a currency at a date
+type Valuation struct {
+	Currency string
+	Date     time.Time
+	// Values of accounts, including subaccounts
+	Values map[*Account]*big.Rat
+	// Costs of accounts, including subaccounts, converted with prices at the time of each posting
+	Costs map[*Account]*big.Rat
+	// Missing holds commodities held by accounts that have no price at date, not counted in Values
+	Missing map[*Account][]string
+	// Uncosted holds commodities posted to accounts that have no price at the time of posting, not counted in Costs
+	Uncosted map[*Account][]string
+}
+
+// Gain returns the unrealized gain of account, or false if some prices are missing
+func (v *Valuation) Gain(account *Account) (*big.Rat, bool) {
+	if len(v.Missing[account]) > 0 || len(v.Uncosted[account]) > 0 {
+		return nil, false
+	}
+	value, ok := v.Values[account]
+	if !ok {
+		return nil, false
+	}
+	return new(big.Rat).Sub(value, v.Costs[account]), true
+}
+
+// Valuate computes market values of all accounts in currency with balances and prices at date.
+// An account named with a share price like 1.234 is taken as holding shares bought at that price,
+// its balance is valued with the price of the commodity named by its parent account, if recorded
+func (l *Ledger) Valuate(currency string, date time.Time) *Valuation {
+	valuation := &Valuation{
+		Currency: currency,
+		Date:     date,
+		Values:   make(map[*Account]*big.Rat),
+		Costs:    make(map[*Account]*big.Rat),
+		Missing:  make(map[*Account][]string),
+		Uncosted: make(map[*Account][]string),
+	}
+
+	// balances and costs of postings at accounts, excluding subaccounts
+	own := make(map[*Account]map[string]*big.Rat)
+	ownCosts := make(map[*Account]*big.Rat)
+	noCost := make(map[*Account]map[string]bool)
+	for _, transaction := range l.Transactions {
+		for _, entry := range transaction.Entries {
+			if entry.Time.After(date) {
+				continue
+			}
+			cost, ok := ownCosts[entry.Account]
+			if !ok {
+				cost = new(big.Rat)
+				ownCosts[entry.Account] = cost
+			}
+			costs := entry.lotCosts()
+			if costs == nil {
+				costs = []costAmount{
+					{entry.Currency, entry.Amount},
+				}
+			}
+			for _, c := range costs {
+				if converted, ok := l.Prices.Convert(c.amount, c.currency, currency, entry.Time); ok {
+					cost.Add(cost, converted)
+				} else {
+					if noCost[entry.Account] == nil {
+						noCost[entry.Account] = make(map[string]bool)
+					}
+					noCost[entry.Account][c.currency] = true
+				}
+			}
+			balances, ok := own[entry.Account]
+			if !ok {
+				balances = make(map[string]*big.Rat)
+				own[entry.Account] = balances
+			}
+			balance, ok := balances[entry.Currency]
+			if !ok {
+				balance = new(big.Rat)
+				balances[entry.Currency] = balance
+			}
+			balance.Add(balance, entry.Amount)
+		}
+	}
+
+	var valuate func(account *Account)
+	valuate = func(account *Account) {
+		value := new(big.Rat)
+		cost := new(big.Rat)
+		if c, ok := ownCosts[account]; ok {
+			cost.Set(c)
+		}
+		missing := make(map[string]bool)
+		uncosted := make(map[string]bool)
+		for commodity := range noCost[account] {
+			uncosted[commodity] = true
+		}
+		for commodity, balance := range own[account] {
+			if balance.Sign() == 0 {
+				continue
+			}
+			if v, ok := l.shareValue(account, commodity, balance, currency, date); ok {
+				value.Add(value, v)
+				continue
+			}
+			rate, ok := l.Prices.Rate(commodity, currency, date)
+			if !ok {
+				missing[commodity] = true
+				continue
+			}
+			value.Add(value, rate.Mul(rate, balance))
+		}
+		for _, sub := range account.Subs {
+			valuate(sub)
+			value.Add(value, valuation.Values[sub])
+			cost.Add(cost, valuation.Costs[sub])
+			for _, commodity := range valuation.Missing[sub] {
+				missing[commodity] = true
+			}
+			for _, commodity := range valuation.Uncosted[sub] {
+				uncosted[commodity] = true
+			}
+		}
+		if len(missing) > 0 {
+			valuation.Missing[account] = sortedKeys(missing)
+		}
+		if len(uncosted) > 0 {
+			valuation.Uncosted[account] = sortedKeys(uncosted)
+		}
+		valuation.Values[account] = value
+		valuation.Costs[account] = cost
+	}
+	valuate(l.Root)
+
+	return valuation
+}
+
+// shareValue values balance of an account named with a share price
+func (l *Ledger) shareValue(
+	account *Account,
+	commodity string,
+	balance *big.Rat,
+	currency string,
+	date time.Time,
+) (*big.Rat, bool) {
+	if account.Parent == nil || !sharePricePattern.MatchString(account.Name) {
+		return nil, false
+	}
+	cost, ok := new(big.Rat).SetString(strings.TrimPrefix(account.Name, "-"))
+	if !ok || cost.Sign() == 0 {
+		return nil, false
+	}
+	price := l.Prices.Lookup(account.Parent.Name, commodity, date)
+	if price == nil {
+		return nil, false
+	}
+	rate, ok := l.Prices.Rate(commodity, currency, date)
+	if !ok {
+		return nil, false
+	}
+	// shares = balance / cost, value = shares * price
+	value := new(big.Rat).Quo(balance, cost)
+	value.Mul(value, price.Amount)
+	return value.Mul(value, rate), true
+}
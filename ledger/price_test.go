@@ -0,0 +1,88 @@
+package ledger
+
+import (
+	"strings"
+	"testing"
+)
+
+const valuationLedger = `price 2020-01-01 AAPL $100
+price 2020-02-01 AAPL $120
+price 2020-02-01 易方达 ￥2.5
+rate 2020-01-01 $ ￥7
+
+2020-01-10 买入
+资产：股票 AAPL10
+权益 AAPL-10
+资产：现金 $-1000
+权益 $1000
+
+2020-01-10 申购
+资产：基金：易方达：1.250 ￥1000
+资产：银行 ￥-1000
+
+2020-01-20 买入
+资产：其他 XYZ5
+权益 XYZ-5
+`
+
+func TestValuate(t *testing.T) {
+	l, err := Parse(strings.NewReader(valuationLedger))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []struct {
+		date    string
+		account string
+		value   string
+		missing string
+	}{
+		{"2020-01-15", "资产：股票", "7000", ""},
+		{"2020-02-15", "资产：股票", "8400", ""},
+		{"2020-02-15", "资产：现金", "-7000", ""},
+		// 1000 / 1.25 = 800 shares at ￥2.5
+		{"2020-02-15", "资产：基金：易方达：1.250", "2000", ""},
+		// no share price recorded, valued at balance
+		{"2020-01-15", "资产：基金", "1000", ""},
+		{"2020-02-15", "资产：其他", "0", "XYZ"},
+		{"2020-02-15", "资产", "2400", "XYZ"},
+	} {
+		valuation := l.Valuate("￥", mustParseDate(c.date))
+		account := findAccount(l.Root, SplitAccount(c.account))
+		if value := valuation.Values[account].RatString(); value != c.value {
+			t.Errorf("%s at %s: expecting %s, got %s", c.account, c.date, c.value, value)
+		}
+		if missing := strings.Join(valuation.Missing[account], " "); missing != c.missing {
+			t.Errorf("%s at %s: expecting missing %q, got %q", c.account, c.date, c.missing, missing)
+		}
+	}
+}
+
+func TestValuationGain(t *testing.T) {
+	l, err := Parse(strings.NewReader(valuationLedger))
+	if err != nil {
+		t.Fatal(err)
+	}
+	valuation := l.Valuate("￥", mustParseDate("2020-02-15"))
+	for _, c := range []struct {
+		account string
+		gain    string
+		ok      bool
+	}{
+		// cost at 2020-01-10 is 10 * $100 * 7
+		{"资产：股票", "1400", true},
+		{"资产：基金", "1000", true},
+		{"资产：现金", "0", true},
+		{"资产：其他", "", false},
+		{"资产", "", false},
+	} {
+		account := findAccount(l.Root, SplitAccount(c.account))
+		gain, ok := valuation.Gain(account)
+		if ok != c.ok {
+			t.Errorf("%s: expecting ok %v, got %v", c.account, c.ok, ok)
+			continue
+		}
+		if ok && gain.RatString() != c.gain {
+			t.Errorf("%s: expecting %s, got %s", c.account, c.gain, gain.RatString())
+		}
+	}
+}
@@ -0,0 +1,178 @@
+package ledger
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// File is the lossless syntax tree of a ledger file.
+// Joining leading and raw lines of blocks and trailing lines with newlines reproduces the file content,
+// with line endings normalized to \n
+type File struct {
+	Path   string
+	Blocks []*Block
+	// Trailing holds raw lines after the last block
+	Trailing []string
+}
+
+type BlockKind int
+
+const (
+	TransactionBlock BlockKind = iota
+	DirectiveBlock
+	// CommentBlock is a block of comment lines only
+	CommentBlock
+)
+
+// Block is a group of non-blank lines, a transaction, directives or comments
+type Block struct {
+	Kind       BlockKind
+	File       string
+	Line       int
+	HeaderDate string
+	Contents   []string
+	// Indents holds the byte length of the leading blanks of each line in Contents
+	Indents []int
+	// Raw holds the lines as in the file
+	Raw []string
+	// Leading holds the raw blank lines before the block
+	Leading []string
+}
+
+// parseFile splits content into blocks
+func parseFile(name string, content string) *File {
+	content = strings.Replace(content, "\r\n", "\n", -1)
+	content = strings.Replace(content, "\r", "\n", -1)
+	file := &File{
+		Path: name,
+	}
+	var contents []string
+	var indents []int
+	var raw []string
+	var blanks []string
+	i := 0
+	addBlock := func() {
+		block := &Block{
+			Kind:     blockKind(contents),
+			File:     name,
+			Line:     i - len(contents),
+			Contents: contents,
+			Indents:  indents,
+			Raw:      raw,
+			Leading:  blanks,
+		}
+		block.HeaderDate = headerDate(contents[block.Header()])
+		file.Blocks = append(file.Blocks, block)
+		contents = nil
+		indents = nil
+		raw = nil
+		blanks = nil
+	}
+	for _, line := range strings.Split(content, "\n") {
+		i++
+		trimmed := strings.TrimSpace(line)
+		if len(trimmed) == 0 {
+			if len(contents) > 0 {
+				addBlock()
+			}
+			blanks = append(blanks, line)
+		} else {
+			contents = append(contents, trimmed)
+			indents = append(indents, len(line)-len(strings.TrimLeftFunc(line, unicode.IsSpace)))
+			raw = append(raw, line)
+		}
+	}
+	if len(contents) > 0 {
+		i++
+		addBlock()
+	}
+	file.Trailing = blanks
+	return file
+}
+
+// Bytes returns the file content
+func (f *File) Bytes() []byte {
+	var lines []string
+	for _, block := range f.Blocks {
+		lines = append(lines, block.Leading...)
+		lines = append(lines, block.Raw...)
+	}
+	lines = append(lines, f.Trailing...)
+	return []byte(strings.Join(lines, "\n"))
+}
+
+func blockKind(contents []string) BlockKind {
+	h := headerIndex(contents)
+	if commentLinePattern.MatchString(contents[h]) {
+		return CommentBlock
+	}
+	if directiveKeywords[blanksPattern.Split(contents[h], 2)[0]] {
+		return DirectiveBlock
+	}
+	return TransactionBlock
+}
+
+// headerDate returns the date of a transaction header or a dated directive in sortable form
+func headerDate(header string) string {
+	parts := blanksPattern.Split(header, 3)
+	date := parts[0]
+	switch blockKind([]string{header}) {
+	case DirectiveBlock:
+		if len(parts) < 2 {
+			return ""
+		}
+		if _, err := ParseDate(parts[1]); err != nil {
+			return ""
+		}
+		date = parts[1]
+	case CommentBlock:
+		return ""
+	}
+	date = strings.Replace(date, "/", "-", -1)
+	date = strings.Replace(date, ".", "-", -1)
+	return date
+}
+
+func sortBlocks(blocks []*Block) {
+	sort.SliceStable(blocks, func(i, j int) bool {
+		return blocks[i].HeaderDate < blocks[j].HeaderDate
+	})
+}
+
+// Header returns the index of the header line in Contents, comment lines before the header are skipped
+func (b *Block) Header() int {
+	return headerIndex(b.Contents)
+}
+
+// headerIndex returns the index of the first non-comment line, 0 if all lines are comments
+func headerIndex(contents []string) int {
+	for n, line := range contents {
+		if !commentLinePattern.MatchString(line) {
+			return n
+		}
+	}
+	return 0
+}
+
+// Metadata returns the key and value of the n-th line if it is a metadata line.
+// Metadata lines are indented key: value lines, the colon is followed by blanks unlike account separators
+func (b *Block) Metadata(n int) (key string, value string, ok bool) {
+	if b.Indents[n] == 0 {
+		return
+	}
+	match := metadataLinePattern.FindStringSubmatch(b.Contents[n])
+	if match == nil {
+		return
+	}
+	return match[1], strings.TrimSpace(match[2]), true
+}
+
+// Pos returns the position of byte offset col in the n-th line of the block
+func (b *Block) Pos(n int, col int) Position {
+	return Position{
+		File:   b.File,
+		Line:   b.Line + n,
+		Column: b.Indents[n] + col + 1,
+	}
+}
@@ -0,0 +1,44 @@
+package ledger
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseFileLossless(t *testing.T) {
+	for _, content := range []string{
+		"",
+		"\n\n",
+		"2020-01-01 a\n资产：现金 ￥1\n收入：工资 ￥-1\n",
+		"// header\r\n\r\n2020-01-01 a\r\n  资产：现金 ￥1  \r\n收入：工资 ￥-1\r\n\r\n\r\n# trailing\r\n",
+		"\topen 2020-01-01 资产：现金\n\n\n2020-01-01 a\n资产：现金 ￥1\n收入：工资 ￥-1",
+	} {
+		file := parseFile("a.ledger", content)
+		expected := strings.Replace(content, "\r\n", "\n", -1)
+		if got := string(file.Bytes()); got != expected {
+			t.Errorf("expecting %q, got %q", expected, got)
+		}
+	}
+}
+
+func TestBlockKind(t *testing.T) {
+	for _, c := range []struct {
+		content string
+		kind    BlockKind
+	}{
+		{"2020-01-01 a\n资产：现金 ￥1", TransactionBlock},
+		{"# a\n2020-01-01 a\n资产：现金 ￥1", TransactionBlock},
+		{"open 2020-01-01 资产：现金", DirectiveBlock},
+		{"open\t2020-01-01 资产：现金", DirectiveBlock},
+		{"# accounts\nopen 2020-01-01 资产：现金", DirectiveBlock},
+		{"# a\n// b", CommentBlock},
+	} {
+		file := parseFile("", c.content)
+		if len(file.Blocks) != 1 {
+			t.Fatalf("%q: expecting 1 block, got %d", c.content, len(file.Blocks))
+		}
+		if kind := file.Blocks[0].Kind; kind != c.kind {
+			t.Errorf("%q: expecting %d, got %d", c.content, c.kind, kind)
+		}
+	}
+}
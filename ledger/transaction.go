@@ -0,0 +1,66 @@
+package ledger
+
+import (
+	"math/big"
+	"time"
+)
+
+type Entry struct {
+	Time  time.Time
+	Year  int
+	Month int
+	Day   int
+
+	Account     *Account
+	Currency    string
+	Amount      *big.Rat
+	Description string
+	Tags        map[string]bool
+	// Metadata holds indented key: value lines after the entry, nil if none
+	Metadata Metadata
+
+	// Lot is the lot annotation, nil if not given
+	Lot *LotSpec
+	// Price is the per-unit price annotation like @$42, nil if not given
+	Price         *big.Rat
+	PriceCurrency string
+	// Matches are parts of lots reduced by the posting
+	Matches []*LotMatch
+
+	Pos Position
+}
+
+type Transaction struct {
+	Date time.Time
+	// Payee is the part before | surrounded by blanks in the header, or the payee metadata, with aliases resolved. Empty if not given
+	Payee       string
+	Description string
+	Entries     []*Entry
+	Assertions  []*Assertion
+	// Metadata holds indented key: value lines before the first entry, nil if none
+	Metadata Metadata
+	TimeFrom time.Time
+	TimeTo   time.Time
+}
+
+// Metadata maps keys of metadata lines to values
+type Metadata map[string]string
+
+// Lookup returns the value of key in the metadata of entry, or of transaction if entry has no such key
+func (t *Transaction) Lookup(entry *Entry, key string) (value string, ok bool) {
+	if entry != nil {
+		if value, ok = entry.Metadata[key]; ok {
+			return
+		}
+	}
+	value, ok = t.Metadata[key]
+	return
+}
+
+// Assertion states the balance of an account in a currency after the transaction
+type Assertion struct {
+	Path     []string
+	Currency string
+	Amount   *big.Rat
+	Pos      Position
+}
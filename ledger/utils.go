@@ -0,0 +1,22 @@
+package ledger
+
+import (
+	"math/big"
+	"sort"
+
+	"github.com/reusee/e/v2"
+)
+
+var (
+	zeroRat = big.NewRat(0, 1)
+	me      = e.Default.WithName("ledger").WithStack()
+	ce, he  = e.New(me)
+)
+
+func sortedKeys(m map[string]bool) (ret []string) {
+	for key := range m {
+		ret = append(ret, key)
+	}
+	sort.Strings(ret)
+	return
+}
@@ -1,700 +1,314 @@
 package main
 
 import (
-	"bytes"
 	"flag"
 	"fmt"
-	"go/ast"
-	"go/parser"
-	"go/token"
-	"io/ioutil"
-	"math/big"
 	"os"
-	"regexp"
-	"sort"
 	"strings"
 	"time"
-	"unicode/utf8"
 
-	"golang.org/x/text/width"
+	"github.com/reusee/keep/ledger"
 )
 
-var (
-	accountSeparatePattern = regexp.MustCompile(`：|:`)
-	sharePricePattern      = regexp.MustCompile(`[0-9]+\.[0-9]{3}`)
-	monthPattern           = regexp.MustCompile(`^[0-9x]{4}$`)
-	datePattern            = regexp.MustCompile(`[0-9]{6}`)
-	blanksPattern          = regexp.MustCompile(`\s+`)
-	inlineDatePattern      = regexp.MustCompile(`@[0-9]{4}[/.-][0-9]{2}[/.-][0-9]{2}`)
-	commentLinePattern     = regexp.MustCompile(`^\s*(#|//)`)
-	yearMonthPattern       = regexp.MustCompile(`[0-9]{4}`)
-	entryTagPattern        = regexp.MustCompile(`<[^>]+>`)
-)
-
-type Account struct {
-	Name        string
-	Subs        map[string]*Account
-	Parent      *Account
-	Balances    map[string]*big.Rat
-	Proportions map[string]*big.Rat
-	TimeFrom    time.Time
+// command is a subcommand of keep
+type command struct {
+	name  string
+	usage string
+	run   func(name string, args []string)
 }
 
-type Entry struct {
-	Time  time.Time
-	Year  int
-	Month int
-	Day   int
-
-	Account     *Account
-	Currency    string
-	Amount      *big.Rat
-	Description string
-	Tags        map[string]bool
+var commands []command
+
+func init() {
+	commands = []command{
+		{"balance", "show the account tree", runBalance},
+		{"register", "show postings of an account with running balances", runRegister},
+		{"income", "show income statement by period", runIncome},
+		{"tags", "show totals of tagged postings by period", runTags},
+		{"payees", "rank spending by payee and period", runPayees},
+		{"balance-sheet", "show assets, liabilities and net assets", runBalanceSheet},
+		{"net-assets", "show monthly changes of net assets", runNetAssets},
+		{"gains", "show realized gains of lots", runGains},
+		{"check", "check ledger files", runCheck},
+		{"fmt", "format ledger files", runFmt},
+		{"sql", "load entries into a database and show SQL ui", runSQL},
+		{"export", "export entries as CSV", runExport},
+	}
 }
 
-type Transaction struct {
-	Date        time.Time
-	Description string
-	Entries     []*Entry
-	TimeFrom    time.Time
-	TimeTo      time.Time
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: %s <command> [options] <file path>...\n\ncommands:\n", os.Args[0])
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "  %-10s%s\n", cmd.name, cmd.usage)
+	}
+	fmt.Fprintf(os.Stderr, "\nwithout a command, balance is run. use %s <command> -h for options\n", os.Args[0])
 }
 
 func main() {
-	var noAmount bool
-	flag.BoolVar(&noAmount, "no-amount", false, "do not display amount")
-	var cmdSQL bool
-	flag.BoolVar(&cmdSQL, "sql", false, "load and show SQL ui")
-
-	flag.Parse()
-
-	// usage
-	args := flag.Args()
+	args := os.Args[1:]
 	if len(args) < 1 {
-		pt("usage: %s [options] <file path>\n", os.Args[0])
-		flag.Usage()
-		return
+		usage()
+		os.Exit(2)
 	}
-
-	// read ledger file
-	ledgerPath := args[0]
-	contentBytes, err := ioutil.ReadFile(ledgerPath)
-	ce(err, "read ledger")
-	content := string(contentBytes)
-	content = strings.Replace(content, "\r\n", "\n", -1)
-	content = strings.Replace(content, "\r", "\n", -1)
-
-	type Block struct {
-		Line       int
-		HeaderDate string
-		Contents   []string
+	switch args[0] {
+	case "help", "-h", "-help", "--help":
+		usage()
+		return
 	}
-
-	// parse blocks
-	var blocks []Block
-	var contents []string
-	i := 0
-	for _, line := range strings.Split(content, "\n") {
-		i++
-		line = strings.TrimSpace(line)
-		if len(line) == 0 {
-			if len(contents) > 0 {
-				date := strings.Split(line, " ")[0]
-				blocks = append(blocks, Block{
-					Line:       i - len(contents),
-					HeaderDate: date,
-					Contents:   contents,
-				})
-				contents = []string{}
-			}
-		} else {
-			contents = append(contents, line)
+	for _, cmd := range commands {
+		if cmd.name == args[0] {
+			cmd.run(cmd.name, args[1:])
+			return
 		}
 	}
-	if len(contents) > 0 {
-		blocks = append(blocks, Block{
-			Line:     i - len(contents),
-			Contents: contents,
-		})
-	}
-	sort.SliceStable(blocks, func(i, j int) bool {
-		block1 := blocks[i]
-		block2 := blocks[j]
-		if block1.HeaderDate != block2.HeaderDate {
-			return block1.HeaderDate < block2.HeaderDate
-		}
-		return block1.Line < block2.Line
-	})
-
-	formatDone := make(chan bool)
-	go func() {
-		// format
-		out := new(bytes.Buffer)
-		write := func(s string) {
-			if _, err := out.WriteString(s); err != nil {
-				panic(err)
-			}
-		}
-		for _, block := range blocks {
-			write(block.Contents[0] + "\n")
-			var lineParts [][]string
-			var widths [3]int
-			for _, line := range block.Contents[1:] {
-				parts := blanksPattern.Split(line, 3)
-				lineParts = append(lineParts, parts)
-				for i, part := range parts {
-					width := displayWidth(part)
-					if width > widths[i] {
-						widths[i] = width
-					}
-				}
-			}
-			for _, parts := range lineParts {
-				for i, part := range parts {
-					if i > 0 && len(part) > 0 {
-						write("    ")
-					}
-					if i == len(parts)-1 {
-						part = strings.TrimRight(part, " ")
-					} else {
-						part = padToLen(part, widths[i])
-					}
-					write(part)
-				}
-				write("\n")
+	if name, args, ok := legacyCommand(args); ok {
+		for _, cmd := range commands {
+			if cmd.name == name {
+				cmd.run(cmd.name, args)
+				return
 			}
-			write("\n")
 		}
-		formatted := false
-		if !bytes.Equal(contentBytes, out.Bytes()) {
-			ce(ioutil.WriteFile(ledgerPath+".tmp", out.Bytes(), 0644))
-			ce(os.Rename(ledgerPath+".tmp", ledgerPath))
-			formatted = true
-		}
-		formatDone <- formatted
-	}()
-
-	// transaction
-	var transactions []*Transaction
-
-	rootAccount := &Account{
-		Name:        "root",
-		Subs:        make(map[string]*Account),
-		Parent:      nil,
-		Balances:    make(map[string]*big.Rat),
-		Proportions: make(map[string]*big.Rat),
 	}
-	var getAccount func(root *Account, path []string) *Account
-	getAccount = func(root *Account, path []string) *Account {
-		if len(path) == 0 {
-			panic(me(nil, "bad account: %v", path))
-		} else if len(path) == 1 {
-			name := path[0]
-			account, ok := root.Subs[name]
-			if ok {
-				return account
-			}
-			account = &Account{
-				Name:        name,
-				Subs:        make(map[string]*Account),
-				Parent:      root,
-				Balances:    make(map[string]*big.Rat),
-				Proportions: make(map[string]*big.Rat),
-			}
-			root.Subs[name] = account
-			return account
-		}
-		name := path[0]
-		account, ok := root.Subs[name]
-		if !ok {
-			account = &Account{
-				Name:        name,
-				Subs:        make(map[string]*Account),
-				Parent:      root,
-				Balances:    make(map[string]*big.Rat),
-				Proportions: make(map[string]*big.Rat),
-			}
-			root.Subs[name] = account
-		}
-		return getAccount(account, path[1:])
-	}
-
-	// collect transactions
-	var lastT time.Time
-	for _, block := range blocks {
-		n := 0
-		transaction := new(Transaction)
-
-		reportError := func(format string, args ...interface{}) {
-			pt(
-				"%s at line %d:\n%s",
-				fmt.Sprintf(format, args...),
-				block.Line,
-				strings.Join(block.Contents, "\n"),
-			)
-			panic("bad block")
-		}
-
-		// parse
-		var t time.Time
-		for _, line := range block.Contents {
-			n++
-
-			if n == 1 {
-				// transaction header
-				parts := blanksPattern.Split(line, 2)
-				if len(parts) != 2 {
-					reportError("bad header")
-				}
-				t = parseDate(parts[0])
-				if transaction.TimeFrom.IsZero() || t.Before(transaction.TimeFrom) {
-					transaction.TimeFrom = t
-				}
-				if transaction.TimeTo.IsZero() || t.After(transaction.TimeTo) {
-					transaction.TimeTo = t
-				}
-				transaction.Description = parts[1]
-
-				if !lastT.IsZero() && t.Before(lastT) {
-					reportError("bad time")
-				}
-				lastT = t
-
-			} else {
-				if commentLinePattern.MatchString(line) {
-					continue
-				}
-
-				// entry
-				parts := blanksPattern.Split(line, 3)
-				if len(parts) < 2 {
-					reportError("bad entry")
-				}
-				entry := new(Entry)
-
-				accountStr := parts[0]
-				account := getAccount(rootAccount, accountSeparatePattern.Split(accountStr, -1))
-				entry.Account = account
-
-				currency, runeSize := utf8.DecodeRuneInString(parts[1])
-				entry.Currency = string(currency)
-				amountStr := parts[1][runeSize:]
-				amount, err := parseAmount(amountStr)
-				if err != nil {
-					reportError("bad amount")
-				}
-				entry.Amount = amount
-
-				if len(parts) > 2 {
-					entry.Description = parts[2]
-				}
-
-				entry.Tags = make(map[string]bool)
-				for _, tag := range entryTagPattern.FindAllString(entry.Description, -1) {
-					entry.Tags[tag] = true
-				}
-
-				var entryTime time.Time
-				if inlineDate := inlineDatePattern.FindString(entry.Description); inlineDate != "" {
-					entryTime = parseDate(inlineDate[1:])
-				} else if yearMonthPattern.MatchString(account.Name) && account.Top().Name == "负债" {
-					entryTime, err = time.Parse("0601", account.Name)
-					ce(err)
-				} else {
-					entryTime = t
-				}
-				entry.Time = entryTime
-				entry.Year = entryTime.Year()
-				entry.Month = int(entryTime.Month())
-				entry.Day = entryTime.Day()
-
-				if account.TimeFrom.IsZero() || entryTime.Before(account.TimeFrom) {
-					account.TimeFrom = entryTime
-				}
-
-				transaction.Entries = append(transaction.Entries, entry)
-			}
+	runBalance("balance", args)
+}
 
-		}
+// legacyFlags are the mode flags before subcommands, mapped to the commands and their flags
+var legacyFlags = map[string][]string{
+	"sql":   {"sql"},
+	"gains": {"gains"},
+	"fmt":   {"fmt"},
+	"check": {"fmt", "-check"},
+	"diff":  {"fmt", "-diff"},
+}
 
-		if t.IsZero() {
-			continue
+// legacyCommand returns the command and its arguments if args has a legacy mode flag like -sql
+func legacyCommand(args []string) (name string, rest []string, ok bool) {
+	var flags []string
+	for _, arg := range args {
+		if arg == "--" {
+			break
 		}
-
-		// check balance
-		sum := big.NewRat(0, 1)
-		for _, entry := range transaction.Entries {
-			sum.Add(sum, entry.Amount)
-			// update account balance
-			account := entry.Account
-			for account != nil {
-				balance, ok := account.Balances[entry.Currency]
-				if !ok {
-					balance = big.NewRat(0, 1)
-					account.Balances[entry.Currency] = balance
-				}
-				balance.Add(balance, entry.Amount)
-				if sharePricePattern.MatchString(account.Name) {
-					isNegative := strings.HasPrefix(account.Parent.Name, "-") ||
-						strings.HasPrefix(account.Name, "-")
-					if !isNegative {
-						if balance.Sign() < 0 {
-							reportError("negative balance in stock share account")
-						}
-					} else {
-						if balance.Sign() > 0 {
-							reportError("positive balance in stock share account")
-						}
-					}
-				}
-				account = account.Parent
+		if mapped, is := legacyFlags[strings.TrimLeft(arg, "-")]; is && strings.HasPrefix(arg, "-") {
+			if name == "" {
+				name = mapped[0]
+				fmt.Fprintf(os.Stderr, "%s is deprecated, use %s %s\n", arg, os.Args[0], strings.Join(mapped, " "))
 			}
+			flags = append(flags, mapped[1:]...)
+			continue
 		}
-		if !(sum.Cmp(zeroRat) == 0) {
-			reportError("not balanced")
-		}
-
-		transactions = append(transactions, transaction)
+		rest = append(rest, arg)
 	}
-
-	if cmdSQL {
-		sqlInterface(rootAccount, transactions)
-		return
+	if name == "" {
+		return "", nil, false
 	}
+	return name, append(flags, rest...), true
+}
 
-	// calculate proportions
-	var calculateProportion func(*Account)
-	calculateProportion = func(account *Account) {
-		for _, sub := range account.Subs {
-			for currency, balance := range sub.Balances {
-				if account.Balances[currency].Sign() != 0 {
-					b := big.NewRat(0, 1)
-					b.Set(balance)
-					sub.Proportions[currency] = b.Quo(balance, account.Balances[currency])
-					b.Abs(b)
-				}
-				calculateProportion(sub)
-			}
-		}
+func newFlagSet(name string) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: %s %s [options] <file path>...\n", os.Args[0], name)
+		flags.PrintDefaults()
 	}
-	calculateProportion(rootAccount)
-
-	// print accounts
-	var printAccount func(account *Account, level int, nameLen int)
-	printAccount = func(account *Account, level int, nameLen int) {
-		allZero := true
-		for _, balance := range account.Balances {
-			abs := new(big.Rat)
-			abs.Set(balance)
-			abs.Abs(abs)
-			if balance.Cmp(zeroRat) != 0 && abs.Cmp(oneCent) >= 0 {
-				allZero = false
-				break
-			}
-		}
-		if allZero && account != rootAccount {
-			return
-		}
-		pt(
-			"%s%s",
-			strings.Repeat(" │    ", level),
-			padToLen(account.Name, nameLen),
-		)
-		var currencyNames []string
-		for name := range account.Balances {
-			currencyNames = append(currencyNames, name)
-		}
-		sort.Strings(currencyNames)
-		for _, name := range currencyNames {
-			balance := account.Balances[name]
-			var proportion string
-			if p, ok := account.Proportions[name]; ok {
-				proportion = " " + p.Mul(p, big.NewRat(100, 1)).FloatString(3) + "%"
-			}
-			if !noAmount {
-				pt(" %s", name)
-			}
-			if !noAmount {
-				if name == "/" {
-					pt("%s", balance.FloatString(0))
-				} else {
-					pt("%s", balance.FloatString(2))
-				}
-			}
-			pt("%s", proportion)
-		}
-		pt("\n")
-
-		var subNames []string
-		for name := range account.Subs {
-			subNames = append(subNames, name)
-		}
+	return flags
+}
 
-		allIsLeaf := func() bool {
-			for _, sub := range account.Subs {
-				sum := big.NewRat(0, 1)
-				for _, balance := range sub.Balances {
-					sum.Add(sum, balance)
-				}
-				if sum.Sign() == 0 {
-					continue
-				}
-				if len(sub.Subs) > 0 {
-					return false
-				}
-			}
-			return true
-		}()
-		allIsMonths := func() bool {
-			if !allIsLeaf {
-				return false
-			}
-			for _, name := range subNames {
-				sub := account.Subs[name]
-				sum := big.NewRat(0, 1)
-				for _, balance := range sub.Balances {
-					sum.Add(sum, balance)
-				}
-				if sum.Sign() == 0 {
-					continue
-				}
-				if !monthPattern.MatchString(name) {
-					return false
-				}
-			}
-			return true
-		}()
-		allIsDate := func() bool {
-			if !allIsLeaf {
-				return false
-			}
-			for _, name := range subNames {
-				sub := account.Subs[name]
-				sum := big.NewRat(0, 1)
-				for _, balance := range sub.Balances {
-					sum.Add(sum, balance)
-				}
-				if sum.Sign() == 0 {
-					continue
-				}
-				if !datePattern.MatchString(name) {
-					return false
-				}
-			}
-			return true
-		}()
-		allIsSharePrices := func() bool {
-			if !allIsLeaf {
-				return false
-			}
-			for _, name := range subNames {
-				sub := account.Subs[name]
-				sum := big.NewRat(0, 1)
-				for _, balance := range sub.Balances {
-					sum.Add(sum, balance)
-				}
-				if sum.Sign() == 0 {
-					continue
-				}
-				if !sharePricePattern.MatchString(name) {
-					return false
-				}
-			}
-			return true
-		}()
-
-		sort.SliceStable(subNames, func(i, j int) bool {
-			a := account.Subs[subNames[i]]
-			b := account.Subs[subNames[j]]
-			weightA, ok1 := sortWeight[sortWeightKey{level + 1, a.Name}]
-			weightB, ok2 := sortWeight[sortWeightKey{level + 1, b.Name}]
-			if ok1 && !ok2 {
-				return false
-			} else if !ok1 && ok2 {
-				return true
-			} else if ok1 && ok2 {
-				return weightA < weightB
-			}
-			// '/' 为单位的
-			if len(account.Balances) == 1 && func() bool {
-				for currency := range account.Balances {
-					if currency == "/" {
-						return true
-					}
-				}
-				return false
-			}() {
-				return a.Name < b.Name
-			}
-			if allIsMonths || allIsDate {
-				// 月份排序
-				return subNames[i] < subNames[j]
-			}
-			if allIsSharePrices {
-				// 价格排序
-				priceA := new(big.Rat)
-				priceA, ok := priceA.SetString(subNames[i])
-				if !ok {
-					panic(fmt.Sprintf("bad price: %s", subNames[i]))
-				}
-				priceB := new(big.Rat)
-				priceB, ok = priceB.SetString(subNames[j])
-				if !ok {
-					panic(fmt.Sprintf("bad price: %s", subNames[j]))
-				}
-				return priceA.Cmp(priceB) > 0
-			}
-			if level == 0 {
-				return subNames[i] < subNames[j]
-			}
-			// 金额排序
-			sumA := big.NewRat(0, 1)
-			for _, balance := range a.Balances {
-				sumA.Add(sumA, balance)
-			}
-			sumB := big.NewRat(0, 1)
-			for _, balance := range b.Balances {
-				sumB.Add(sumB, balance)
-			}
-			return sumA.Cmp(sumB) > 0
-		})
-		subNameLen := 0
-		for _, name := range subNames {
-			l := displayWidth(name)
-			if l > subNameLen {
-				subNameLen = l
-			}
-		}
-		skip := false
-		for _, name := range subNames {
-			subAccount := account.Subs[name]
-			if time.Until(subAccount.TimeFrom) > time.Hour*24*30*3 {
-				skip = true
-				continue
-			}
-			printAccount(subAccount, level+1, subNameLen)
-		}
-		if skip {
-			pt(
-				"%s[...]\n",
-				strings.Repeat(" │    ", level+1),
-			)
-		}
+func runBalance(name string, args []string) {
+	var opts options
+	flags := newFlagSet(name)
+	opts.addFileFlags(flags)
+	opts.addFilterFlags(flags)
+	noAmount := flags.Bool("no-amount", false, "do not display amount")
+	valueCurrency := flags.String("value", "", "show market values and unrealized gains in `currency`")
+	currency := flags.String("currency", "", "convert all balances to `currency` and show net worth")
+	flags.Parse(args)
+
+	l := opts.load(flags).Filter(opts.filter())
+	date := opts.date()
+	if *currency != "" {
+		printConvertedTree(l, *currency, date, *noAmount)
+		return
 	}
-	printAccount(rootAccount, 0, 0)
-
-	formatted := <-formatDone
-	if formatted {
-		pt("formatted\n")
+	var valuation *ledger.Valuation
+	if *valueCurrency != "" {
+		valuation = l.Valuate(*valueCurrency, date)
 	}
+	printTree(l.Root, *noAmount, valuation, date)
 }
 
-func parseAmount(str string) (*big.Rat, error) {
-	expr, err := parser.ParseExpr(str)
-	if err != nil {
-		return nil, err
+func runRegister(name string, args []string) {
+	var opts options
+	flags := newFlagSet(name)
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: %s %s [options] <account> <file path>...\n", os.Args[0], name)
+		flags.PrintDefaults()
 	}
-	value, err := evalExpr(expr)
-	if err != nil {
-		return nil, err
+	opts.addFileFlags(flags)
+	opts.addFilterFlags(flags)
+	flags.Parse(args)
+	if flags.NArg() < 1 {
+		flags.Usage()
+		os.Exit(2)
 	}
-	return value, nil
+	opts.accounts = append(opts.accounts, flags.Arg(0))
+	if err := flags.Parse(flags.Args()[1:]); err != nil {
+		ce(err)
+	}
+
+	printRegister(opts.load(flags), opts.filter())
 }
 
-func evalExpr(expr ast.Expr) (result *big.Rat, err error) {
-	defer he(&err)
+func runIncome(name string, args []string) {
+	var opts options
+	flags := newFlagSet(name)
+	opts.addFileFlags(flags)
+	opts.addFilterFlags(flags)
+	periodName := flags.String("period", "month", "`period` of statement: year, quarter, month, week or day")
+	flags.Parse(args)
+	period, layout := parsePeriod(*periodName)
 
-	switch expr := expr.(type) {
+	printIncomeStatement(opts.load(flags).Filter(opts.filter()), period, layout)
+}
 
-	case *ast.BasicLit:
-		r := new(big.Rat)
-		a, ok := r.SetString(expr.Value)
-		if !ok {
-			return nil, me(nil, "bad expression: %s", expr.Value)
-		}
-		return a, nil
-
-	case *ast.UnaryExpr:
-		switch expr.Op {
-		case token.SUB:
-			v, err := evalExpr(expr.X)
-			ce(err)
-			return v.Neg(v), nil
-		}
+func runTags(name string, args []string) {
+	var opts options
+	flags := newFlagSet(name)
+	opts.addFileFlags(flags)
+	opts.addFilterFlags(flags)
+	periodName := flags.String("period", "month", "`period` of totals: year, quarter, month, week or day")
+	flags.Parse(args)
+	period, layout := parsePeriod(*periodName)
 
-	case *ast.BinaryExpr:
-		x, err := evalExpr(expr.X)
-		ce(err)
-		y, err := evalExpr(expr.Y)
-		ce(err)
-		switch expr.Op {
-		case token.MUL:
-			return x.Mul(x, y), nil
-		case token.SUB:
-			return x.Sub(x, y), nil
-		case token.ADD:
-			return x.Add(x, y), nil
-		case token.QUO:
-			return x.Quo(x, y), nil
-		}
-
-	case *ast.ParenExpr:
-		return evalExpr(expr.X)
+	printTagTotals(opts.load(flags).Filter(opts.filter()), period, layout)
+}
 
-	}
+func runPayees(name string, args []string) {
+	var opts options
+	flags := newFlagSet(name)
+	opts.addFileFlags(flags)
+	opts.addFilterFlags(flags)
+	periodName := flags.String("period", "month", "`period` of ranking: year, quarter, month, week or day")
+	flags.Parse(args)
+	period, layout := parsePeriod(*periodName)
 
-	pt("%#v\n", expr)
-	return nil, me(nil, "unkndown expr")
+	printPayeeSpending(opts.load(flags).Filter(opts.filter()), period, layout)
+}
 
+func runBalanceSheet(name string, args []string) {
+	var opts options
+	flags := newFlagSet(name)
+	opts.addFileFlags(flags)
+	flags.StringVar(&opts.asOf, "as-of", "", "show balance sheet at `date`")
+	opts.addTagFlag(flags)
+	flags.Parse(args)
+
+	printBalanceSheet(opts.load(flags).Filter(ledger.Filter{
+		Tags: opts.filter().Tags,
+	}), opts.date())
 }
 
-func displayWidth(s string) int {
-	l := 0
-	for _, r := range s {
-		properties := width.LookupRune(r)
-		switch properties.Kind() {
-		case width.EastAsianWide, width.EastAsianFullwidth:
-			l += 2
-		default:
-			l += 1
-		}
+func runNetAssets(name string, args []string) {
+	var opts options
+	flags := newFlagSet(name)
+	opts.addFileFlags(flags)
+	flags.StringVar(&opts.begin, "begin", "", "show months at or after `date`")
+	flags.StringVar(&opts.end, "end", "", "show months before `date`, default to now")
+	opts.addTagFlag(flags)
+	flags.Parse(args)
+
+	filter := opts.filter()
+	if filter.End.IsZero() {
+		filter.End = time.Now()
 	}
-	return l
+	l := opts.load(flags).Filter(ledger.Filter{
+		Tags: filter.Tags,
+	})
+	printNetAssetChanges(l, filter.Begin, filter.End)
 }
 
-func padToLen(s string, l int) string {
-	b := new(strings.Builder)
-	b.WriteString(s)
-	l -= displayWidth(s)
-	for ; l > 0; l-- {
-		b.WriteByte(' ')
-	}
-	return b.String()
+func runGains(name string, args []string) {
+	var opts options
+	flags := newFlagSet(name)
+	opts.addFileFlags(flags)
+	opts.addFilterFlags(flags)
+	flags.Parse(args)
+
+	printGains(opts.load(flags).Filter(opts.filter()))
+}
+
+func runCheck(name string, args []string) {
+	var opts options
+	flags := newFlagSet(name)
+	opts.addFileFlags(flags)
+	flags.Parse(args)
+
+	// load exits with non-zero status on errors
+	opts.load(flags)
 }
 
-func (a *Account) Top() *Account {
-	ret := a
-	for ret.Parent != nil && ret.Parent.Parent != nil {
-		ret = ret.Parent
+func runFmt(name string, args []string) {
+	var opts options
+	flags := newFlagSet(name)
+	opts.addFileFlags(flags)
+	check := flags.Bool("check", false, "do not write files, exit with non-zero status if ledger files are not formatted")
+	diff := flags.Bool("diff", false, "do not write files, print changes of formatting as unified diff")
+	alignFile := flags.Bool("align-file", false, "align columns across the whole file")
+	flags.Parse(args)
+
+	l := opts.load(flags)
+	write := !*check && !*diff
+	unformatted := formatLedger(l, write, *diff, *alignFile)
+	for _, path := range unformatted {
+		if write {
+			pt("formatted: %s\n", path)
+		} else if !*diff {
+			pt("%s\n", path)
+		}
+	}
+	if *check && len(unformatted) > 0 {
+		os.Exit(1)
 	}
-	return ret
 }
 
-func (a *Account) MatchPath(path []string) bool {
-	c := a
-	for i := len(path) - 1; i >= 0; i-- {
-		if c.Name == path[i] {
-			c = c.Parent
+func runSQL(name string, args []string) {
+	var opts options
+	flags := newFlagSet(name)
+	opts.addFileFlags(flags)
+	opts.addFilterFlags(flags)
+	backend := flags.String("backend", "postgres", "database `backend`: postgres or sqlite")
+	dbPath := flags.String("db", "", "SQLite database `file`, in memory if not given")
+	dsn := flags.String("dsn", "", "load into the existing PostgreSQL database of `dsn` and keep the data, instead of a temporary cluster")
+	schema := flags.String("schema", "keep", "PostgreSQL `schema` of tables and views")
+	var query queryOptions
+	query.addFlags(flags)
+	flags.Parse(args)
+
+	queries := query.queries()
+	l := opts.load(flags).Filter(opts.filter())
+	var ok bool
+	switch *backend {
+	case "postgres":
+		if *dsn != "" {
+			ok = dsnInterface(l, *dsn, *schema, queries, query.format)
+		} else {
+			ok = sqlInterface(l, *schema, queries, query.format)
 		}
+	case "sqlite":
+		ok = sqliteInterface(l, *dbPath, queries, query.format)
+	default:
+		ce(me(nil, "unknown backend: %s", *backend))
+	}
+	if !ok {
+		os.Exit(1)
 	}
-	return c.Parent == nil // is root
 }
 
-func parseDate(str string) time.Time {
-	str = strings.Replace(str, "/", "-", -1)
-	str = strings.Replace(str, ".", "-", -1)
-	t, err := time.Parse("2006-01-02", str)
-	ce(err, "bad date: %s", str)
-	return t
+func runExport(name string, args []string) {
+	var opts options
+	flags := newFlagSet(name)
+	opts.addFileFlags(flags)
+	opts.addFilterFlags(flags)
+	flags.Parse(args)
+
+	exportCSV(opts.load(flags).Filter(opts.filter()))
 }
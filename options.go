@@ -0,0 +1,132 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/reusee/keep/ledger"
+)
+
+// stringsFlag is a flag that can be given multiple times
+type stringsFlag []string
+
+func (s *stringsFlag) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *stringsFlag) Set(value string) error {
+	*s = append(*s, value)
+	return nil
+}
+
+// options holds the options shared by subcommands
+type options struct {
+	files    stringsFlag
+	config   string
+	strict   bool
+	begin    string
+	end      string
+	asOf     string
+	accounts stringsFlag
+	metadata stringsFlag
+	tags     stringsFlag
+}
+
+// addFileFlags registers options of file selection
+func (o *options) addFileFlags(flags *flag.FlagSet) {
+	flags.Var(&o.files, "f", "ledger `file`, may be repeated; files can also be given as arguments")
+	flags.StringVar(&o.config, "config", "", "config `file`, default to "+configFileName+" next to the first ledger file if exists")
+	flags.BoolVar(&o.strict, "strict", false, "reject postings to undeclared accounts")
+}
+
+// addFilterFlags registers options of entry selection
+func (o *options) addFilterFlags(flags *flag.FlagSet) {
+	flags.StringVar(&o.begin, "begin", "", "select entries at or after `date`")
+	flags.StringVar(&o.end, "end", "", "select entries before `date`")
+	flags.StringVar(&o.asOf, "as-of", "", "select entries at or before `date`, for balances at the date")
+	flags.Var(&o.accounts, "account", "select entries of `account` and its subaccounts, may be repeated")
+	flags.Var(&o.metadata, "meta", "select entries with metadata `key=value` in the entry or its transaction, or with the key if value is empty, may be repeated")
+	o.addTagFlag(flags)
+}
+
+// addTagFlag registers the tag option, for subcommands without other filter options
+func (o *options) addTagFlag(flags *flag.FlagSet) {
+	flags.Var(&o.tags, "tag", "select entries with `tag`, with or without <>, may be repeated")
+}
+
+// load reads the config, parses the selected files, prints diagnostics, and exits on errors
+func (o *options) load(flags *flag.FlagSet) *ledger.Ledger {
+	paths := append(o.files, flags.Args()...)
+	if len(paths) == 0 {
+		fmt.Fprintf(os.Stderr, "no ledger file\n")
+		flags.Usage()
+		os.Exit(2)
+	}
+	loadConfig(o.config, paths[0])
+	l, err := ledger.Parser{
+		Strict:       o.strict,
+		AccountTypes: accountTypes,
+		PayeeAliases: payeeAliases,
+	}.ParseFiles(paths...)
+	if l != nil {
+		for _, diag := range l.Diagnostics {
+			fmt.Fprintf(os.Stderr, "%s\n", diag.Error())
+		}
+	}
+	if _, ok := err.(ledger.Diagnostics); ok {
+		os.Exit(1)
+	}
+	ce(err)
+	return l
+}
+
+// filter returns the entry filter of the options
+func (o *options) filter() ledger.Filter {
+	var f ledger.Filter
+	var err error
+	if o.begin != "" {
+		f.Begin, err = ledger.ParseDate(o.begin)
+		ce(err)
+	}
+	if o.end != "" && o.asOf != "" {
+		ce(me(nil, "-end and -as-of are exclusive"))
+	}
+	if o.end != "" {
+		f.End, err = ledger.ParseDate(o.end)
+		ce(err)
+	}
+	if o.asOf != "" {
+		asOf, err := ledger.ParseDate(o.asOf)
+		ce(err)
+		f.End = asOf.AddDate(0, 0, 1)
+	}
+	for _, account := range o.accounts {
+		f.Accounts = append(f.Accounts, ledger.SplitAccount(account))
+	}
+	for _, meta := range o.metadata {
+		if f.Metadata == nil {
+			f.Metadata = make(map[string]string)
+		}
+		key := meta
+		var value string
+		if i := strings.Index(meta, "="); i >= 0 {
+			key, value = meta[:i], meta[i+1:]
+		}
+		f.Metadata[key] = value
+	}
+	for _, tag := range o.tags {
+		f.Tags = append(f.Tags, strings.TrimSuffix(strings.TrimPrefix(tag, "<"), ">"))
+	}
+	return f
+}
+
+// date returns the date that reports refer to: the as-of date, the day before the end date, or today
+func (o *options) date() time.Time {
+	if f := o.filter(); !f.End.IsZero() {
+		return f.End.AddDate(0, 0, -1)
+	}
+	return time.Now()
+}
@@ -0,0 +1,334 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// queryOptions are options of running queries
+type queryOptions struct {
+	exprs  stringsFlag
+	file   string
+	format string
+}
+
+// addFlags registers options of non-interactive queries
+func (q *queryOptions) addFlags(flags *flag.FlagSet) {
+	flags.Var(&q.exprs, "e", "run `query` and exit instead of showing the prompt, may be repeated")
+	flags.StringVar(&q.file, "query-file", "", "run queries in `file` and exit, - for stdin")
+	flags.StringVar(&q.format, "format", "table", "output `format` of queries: table, csv or json; json results of multiple queries are wrapped in an array")
+}
+
+// queries returns the queries to run, nil for the prompt
+func (q *queryOptions) queries() (queries []string) {
+	switch q.format {
+	case "table", "csv", "json":
+	default:
+		ce(me(nil, "unknown format: %s", q.format))
+	}
+	queries = append(queries, q.exprs...)
+	if q.file != "" {
+		r := os.Stdin
+		if q.file != "-" {
+			f, err := os.Open(q.file)
+			ce(err)
+			defer f.Close()
+			r = f
+		}
+		statements, err := splitStatements(r)
+		ce(err, "read %s", q.file)
+		queries = append(queries, statements...)
+	}
+	return
+}
+
+// runQueries runs queries and prints the results in format, or shows the prompt if there is no query.
+// In json format, results of multiple queries are elements of an array, null for failed queries.
+// Returns false if any query failed
+func runQueries(db *sqlx.DB, queries []string, format string) bool {
+	if len(queries) == 0 {
+		queryPrompt(db, os.Stdin)
+		return true
+	}
+	ok := true
+	var results []string
+	defer func() {
+		if len(queries) > 1 && format == "json" {
+			pt("[\n%s]\n", strings.Join(results, ",\n"))
+		}
+	}()
+	for i, query := range queries {
+		header, types, rows, err := queryRows(db, query)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			ok = false
+			results = append(results, "null")
+			continue
+		}
+		if format == "json" {
+			buf := new(strings.Builder)
+			ce(writeJSONRows(buf, header, types, rows))
+			if len(queries) == 1 {
+				pt("%s", buf.String())
+			}
+			results = append(results, strings.TrimSuffix(buf.String(), "\n"))
+			continue
+		}
+		if len(header) == 0 {
+			continue
+		}
+		switch format {
+		case "csv":
+			if i > 0 {
+				pt("\n")
+			}
+			w := csv.NewWriter(os.Stdout)
+			ce(w.Write(header))
+			for _, row := range rows {
+				cells := make([]string, 0, len(row))
+				for _, value := range row {
+					cells = append(cells, formatSQLValue(value))
+				}
+				ce(w.Write(cells))
+			}
+			w.Flush()
+			ce(w.Error())
+		default:
+			printCells(header, rows)
+		}
+	}
+	return ok
+}
+
+// splitStatements splits r into statements terminated by ; at line ends
+func splitStatements(r io.Reader) (statements []string, err error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	var query strings.Builder
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if query.Len() == 0 && line == "" {
+			continue
+		}
+		query.WriteString(line)
+		query.WriteString("\n")
+		if strings.HasSuffix(line, ";") {
+			statements = append(statements, query.String())
+			query.Reset()
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(query.String()) != "" {
+		statements = append(statements, query.String())
+	}
+	return
+}
+
+// queryPrompt reads statements terminated by ; from r and prints the results.
+// .tables lists tables and views, .quit exits
+func queryPrompt(db *sqlx.DB, r io.Reader) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	var query strings.Builder
+	prompt := func() {
+		if query.Len() == 0 {
+			pt("keep> ")
+		} else {
+			pt("  ... ")
+		}
+	}
+	prompt()
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if query.Len() == 0 {
+			switch line {
+			case "":
+				prompt()
+				continue
+			case ".quit", ".exit":
+				return
+			case ".tables":
+				if db.DriverName() == "postgres" {
+					runQuery(db, `select table_name as name, table_type as type from information_schema.tables where table_schema = current_schema() order by type, name`)
+				} else {
+					runQuery(db, `select name, type from sqlite_master where type in ('table', 'view') and name not like 'sqlite_%' order by type, name`)
+				}
+				prompt()
+				continue
+			}
+		}
+		query.WriteString(line)
+		query.WriteString("\n")
+		if strings.HasSuffix(line, ";") {
+			runQuery(db, query.String())
+			query.Reset()
+		}
+		prompt()
+	}
+	ce(scanner.Err())
+	pt("\n")
+}
+
+// runQuery executes query and prints the result rows, errors are printed and not fatal
+func runQuery(db *sqlx.DB, query string) {
+	header, _, rows, err := queryRows(db, query)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return
+	}
+	if len(header) == 0 {
+		return
+	}
+	printCells(header, rows)
+}
+
+// queryRows executes query and returns the column names, the upper-cased database types of columns and the rows.
+// Text values are returned as strings
+func queryRows(db *sqlx.DB, query string) (header []string, types []string, rows [][]any, err error) {
+	defer he(&err)
+	res, err := db.Queryx(query)
+	ce(err)
+	defer res.Close()
+	header, err = res.Columns()
+	ce(err)
+	columnTypes, err := res.ColumnTypes()
+	ce(err)
+	for _, t := range columnTypes {
+		types = append(types, strings.ToUpper(t.DatabaseTypeName()))
+	}
+	for res.Next() {
+		values, err := res.SliceScan()
+		ce(err)
+		for i, value := range values {
+			if b, ok := value.([]byte); ok {
+				values[i] = string(b)
+			}
+		}
+		rows = append(rows, values)
+	}
+	ce(res.Err())
+	return
+}
+
+func formatSQLValue(value any) string {
+	switch value := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return value
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	case fmt.Stringer:
+		return value.String()
+	}
+	return fmt.Sprint(value)
+}
+
+// jsonValue returns value of a column of database type typ for JSON output.
+// JSON columns and text arrays are written as JSON values, numerics as numbers, dates at midnight as dates like SQLite stores them.
+// SQLite has no JSON type for expressions, so text arrays and objects of columns without declared type are written as JSON values
+func jsonValue(typ string, value any) any {
+	if t, ok := value.(time.Time); ok && t.Equal(time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())) {
+		return t.Format("2006-01-02")
+	}
+	s, ok := value.(string)
+	if !ok {
+		return value
+	}
+	switch typ {
+	case "JSON", "JSONB":
+		if json.Valid([]byte(s)) {
+			return json.RawMessage(s)
+		}
+	case "NUMERIC", "DECIMAL":
+		if _, err := strconv.ParseFloat(s, 64); err == nil {
+			return json.Number(s)
+		}
+	case "_TEXT", "_VARCHAR":
+		var array pq.StringArray
+		if err := array.Scan([]byte(s)); err == nil {
+			return []string(array)
+		}
+	case "":
+		if (strings.HasPrefix(s, "[") || strings.HasPrefix(s, "{")) && json.Valid([]byte(s)) {
+			return json.RawMessage(s)
+		}
+	}
+	return value
+}
+
+// writeJSONRows writes rows as a JSON array of objects with keys in column order, values converted by jsonValue
+func writeJSONRows(w io.Writer, header []string, types []string, rows [][]any) error {
+	buf := new(bytes.Buffer)
+	buf.WriteString("[")
+	for i, row := range rows {
+		if i > 0 {
+			buf.WriteString(",")
+		}
+		buf.WriteString("\n  {")
+		for j, value := range row {
+			if j > 0 {
+				buf.WriteString(", ")
+			}
+			key, err := json.Marshal(header[j])
+			if err != nil {
+				return err
+			}
+			buf.Write(key)
+			buf.WriteString(": ")
+			v, err := json.Marshal(jsonValue(types[j], value))
+			if err != nil {
+				return err
+			}
+			buf.Write(v)
+		}
+		buf.WriteString("}")
+	}
+	if len(rows) > 0 {
+		buf.WriteString("\n")
+	}
+	buf.WriteString("]\n")
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// printCells prints rows as a table, cells with multiple lines span multiple table lines
+func printCells(header []string, rows [][]any) {
+	var lines [][]string
+	for _, row := range rows {
+		var cells [][]string
+		n := 1
+		for _, value := range row {
+			cellLines := strings.Split(formatSQLValue(value), "\n")
+			if len(cellLines) > n {
+				n = len(cellLines)
+			}
+			cells = append(cells, cellLines)
+		}
+		for i := 0; i < n; i++ {
+			line := make([]string, len(row))
+			for j, cellLines := range cells {
+				if i < len(cellLines) {
+					line[j] = cellLines[i]
+				}
+			}
+			lines = append(lines, line)
+		}
+	}
+	printTable(header, lines)
+	pt("(%d rows)\n", len(rows))
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"math/big"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/reusee/keep/ledger"
+)
+
+// printRegister prints postings selected by filter in time order, with running balances per currency.
+// Postings before filter.Begin are not printed but counted in the running balances
+func printRegister(l *ledger.Ledger, filter ledger.Filter) {
+	type posting struct {
+		transaction *ledger.Transaction
+		entry       *ledger.Entry
+	}
+	begin := filter.Begin
+	filter.Begin = time.Time{}
+	var postings []posting
+	accounts := make(map[*ledger.Account]bool)
+	for _, transaction := range l.Transactions {
+		for _, entry := range transaction.Entries {
+			if !filter.Match(transaction, entry) {
+				continue
+			}
+			postings = append(postings, posting{transaction, entry})
+			accounts[entry.Account] = true
+		}
+	}
+	sort.SliceStable(postings, func(i, j int) bool {
+		return postings[i].entry.Time.Before(postings[j].entry.Time)
+	})
+
+	var rows [][]string
+	balances := make(map[string]*big.Rat)
+	for _, p := range postings {
+		balance, ok := balances[p.entry.Currency]
+		if !ok {
+			balance = new(big.Rat)
+			balances[p.entry.Currency] = balance
+		}
+		balance.Add(balance, p.entry.Amount)
+		if !begin.IsZero() && p.entry.Time.Before(begin) {
+			continue
+		}
+		description := p.transaction.Description
+		if p.transaction.Payee != "" {
+			description = p.transaction.Payee + " | " + description
+		}
+		row := []string{
+			p.entry.Time.Format("2006-01-02"),
+			description,
+		}
+		if len(accounts) > 1 {
+			row = append(row, strings.Join(p.entry.Account.Path(), "："))
+		}
+		row = append(row,
+			p.entry.Description,
+			ledger.FormatAmount(p.entry.Currency, p.entry.Amount, 2),
+			ledger.FormatAmount(p.entry.Currency, balance, 2),
+		)
+		rows = append(rows, row)
+	}
+	if len(rows) == 0 {
+		return
+	}
+	header := []string{"date", "transaction"}
+	if len(accounts) > 1 {
+		header = append(header, "account")
+	}
+	header = append(header, "description", "amount", "balance")
+	printTable(header, rows)
+}
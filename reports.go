@@ -0,0 +1,516 @@
+package main
+
+import (
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/reusee/keep/ledger"
+)
+
+// periods truncate a time to the beginning of its period
+var periods = map[string]func(time.Time) time.Time{
+	"year": func(t time.Time) time.Time {
+		return time.Date(t.Year(), 1, 1, 0, 0, 0, 0, t.Location())
+	},
+	"quarter": func(t time.Time) time.Time {
+		return time.Date(t.Year(), (t.Month()-1)/3*3+1, 1, 0, 0, 0, 0, t.Location())
+	},
+	"month": func(t time.Time) time.Time {
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location())
+	},
+	"week": func(t time.Time) time.Time {
+		t = time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+		return t.AddDate(0, 0, -(int(t.Weekday())+6)%7)
+	},
+	"day": func(t time.Time) time.Time {
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	},
+}
+
+// parsePeriod returns the truncation func of period name and the layout of period beginnings
+func parsePeriod(name string) (period func(time.Time) time.Time, layout string) {
+	period, ok := periods[name]
+	if !ok {
+		ce(me(nil, "unknown period: %s", name))
+	}
+	layout = "2006-01-02"
+	switch name {
+	case "year":
+		layout = "2006"
+	case "quarter", "month":
+		layout = "2006-01"
+	}
+	return
+}
+
+// amountSum is the sum of postings, with increases and decreases
+type amountSum struct {
+	Total    *big.Rat
+	Increase *big.Rat
+	Decrease *big.Rat
+}
+
+func (s *amountSum) add(amount *big.Rat) {
+	s.Total.Add(s.Total, amount)
+	if amount.Sign() >= 0 {
+		s.Increase.Add(s.Increase, amount)
+	} else {
+		s.Decrease.Sub(s.Decrease, amount)
+	}
+}
+
+// amountSums is amounts summed by keys
+type amountSums map[sumKey]*amountSum
+
+type sumKey struct {
+	Period   time.Time
+	Currency string
+	// Top is the first level account name
+	Top string
+	// Kind is the second level account name, empty for the sum of all kinds
+	Kind string
+}
+
+func (s amountSums) add(key sumKey, amount *big.Rat) {
+	sum, ok := s[key]
+	if !ok {
+		sum = &amountSum{
+			Total:    new(big.Rat),
+			Increase: new(big.Rat),
+			Decrease: new(big.Rat),
+		}
+		s[key] = sum
+	}
+	sum.add(amount)
+}
+
+// addEntry adds the amount of entry to the sums of its top account and kind
+func (s amountSums) addEntry(period time.Time, entry *ledger.Entry) {
+	path := entry.Account.Path()
+	key := sumKey{
+		Period:   period,
+		Currency: entry.Currency,
+		Top:      path[0],
+	}
+	s.add(key, entry.Amount)
+	if len(path) > 1 {
+		key.Kind = path[1]
+		s.add(key, entry.Amount)
+	}
+}
+
+// kinds returns kind sums of top account in period and currency, ordered by total, descending if desc
+func (s amountSums) kinds(period time.Time, currency string, top string, desc bool) (kinds []string) {
+	for key := range s {
+		if key.Period.Equal(period) && key.Currency == currency && key.Top == top && key.Kind != "" {
+			kinds = append(kinds, key.Kind)
+		}
+	}
+	sort.Slice(kinds, func(i, j int) bool {
+		a := s[sumKey{period, currency, top, kinds[i]}].Total
+		b := s[sumKey{period, currency, top, kinds[j]}].Total
+		if c := a.Cmp(b); c != 0 {
+			return (c > 0) == desc
+		}
+		return kinds[i] < kinds[j]
+	})
+	return
+}
+
+// total returns the sum of total amounts of top accounts in period and currency
+func (s amountSums) total(period time.Time, currency string, tops []string) (total *big.Rat, ok bool) {
+	total = new(big.Rat)
+	for _, top := range tops {
+		if sum, has := s[sumKey{period, currency, top, ""}]; has {
+			total.Add(total, sum.Total)
+			ok = true
+		}
+	}
+	return
+}
+
+// periodCurrencies returns periods in descending order and currencies in ascending order
+func (s amountSums) periodCurrencies() (ret []sumKey) {
+	seen := make(map[sumKey]bool)
+	for key := range s {
+		k := sumKey{
+			Period:   key.Period,
+			Currency: key.Currency,
+		}
+		if !seen[k] {
+			seen[k] = true
+			ret = append(ret, k)
+		}
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		if !ret[i].Period.Equal(ret[j].Period) {
+			return ret[i].Period.After(ret[j].Period)
+		}
+		return ret[i].Currency < ret[j].Currency
+	})
+	return
+}
+
+// printIncomeStatement prints expenses, income, net income and changes of assets and liabilities per period and currency
+func printIncomeStatement(l *ledger.Ledger, period func(time.Time) time.Time, layout string) {
+	sums := make(amountSums)
+	for _, transaction := range l.Transactions {
+		for _, entry := range transaction.Entries {
+			sums.addEntry(period(entry.Time), entry)
+		}
+	}
+
+	var rows [][]string
+	for _, pc := range sums.periodCurrencies() {
+		span := pc.Period.Format(layout)
+		format := func(amount *big.Rat) string {
+			return ledger.FormatAmount(pc.Currency, amount, 2)
+		}
+		for _, section := range []struct {
+			typ      ledger.AccountType
+			desc     bool
+			changes  bool
+			increase string
+			decrease string
+		}{
+			{ledger.ExpenseAccount, true, false, "", ""},
+			{ledger.IncomeAccount, false, false, "", ""},
+			{ledger.AssetAccount, true, true, "增", "减"},
+			{ledger.LiabilityAccount, false, true, "还", "借"},
+		} {
+			tops := l.AccountTypes.Roots(section.typ)
+			for _, top := range tops {
+				sum, ok := sums[sumKey{pc.Period, pc.Currency, top, ""}]
+				if !ok {
+					continue
+				}
+				rows = append(rows, []string{span, top, format(sum.Total), "", ""})
+				for _, kind := range sums.kinds(pc.Period, pc.Currency, top, section.desc) {
+					sum := sums[sumKey{pc.Period, pc.Currency, top, kind}]
+					row := []string{span, "  " + kind, format(sum.Total), "", ""}
+					if section.changes {
+						row[3] = section.increase + format(sum.Increase)
+						row[4] = section.decrease + format(sum.Decrease)
+					}
+					rows = append(rows, row)
+				}
+			}
+			if section.typ == ledger.IncomeAccount {
+				if income, ok := sums.total(pc.Period, pc.Currency, tops); ok {
+					net := new(big.Rat).Neg(income)
+					expenses, _ := sums.total(pc.Period, pc.Currency, l.AccountTypes.Roots(ledger.ExpenseAccount))
+					net.Sub(net, expenses)
+					rows = append(rows, []string{span, "净收入", format(net), "", ""})
+				}
+			}
+		}
+	}
+	if len(rows) == 0 {
+		return
+	}
+	printTable([]string{"period", "account", "amount", "increase", "decrease"}, rows)
+}
+
+// liabilityHorizons are the periods of future liabilities shown in balance sheet
+var liabilityHorizons = []struct {
+	name   string
+	years  int
+	months int
+}{
+	{"一月", 0, 1},
+	{"一年", 1, 0},
+	{"三年", 3, 0},
+}
+
+// printBalanceSheet prints assets, liabilities and net assets at date, liquid assets,
+// and liabilities due in the horizons.
+// Liabilities are selected by transaction date, since postings to liabilities are dated by due month
+func printBalanceSheet(l *ledger.Ledger, date time.Time) {
+	end := date.AddDate(0, 0, 1)
+	sums := make(amountSums)
+	dues := make(amountSums)
+	var zero time.Time
+	liquid := make(map[string]bool)
+	for _, name := range liquidAssets {
+		liquid[name] = true
+	}
+	for _, transaction := range l.Transactions {
+		for _, entry := range transaction.Entries {
+			path := entry.Account.Path()
+			switch l.AccountTypes.Of(entry.Account) {
+			case ledger.AssetAccount:
+				if !entry.Time.Before(end) {
+					continue
+				}
+				sums.addEntry(zero, entry)
+				if len(path) > 1 && liquid[path[1]] {
+					sums.add(sumKey{zero, entry.Currency, "T+0流动资产", ""}, entry.Amount)
+					sums.add(sumKey{zero, entry.Currency, "T+0流动资产", path[1]}, entry.Amount)
+				}
+			case ledger.LiabilityAccount:
+				if !transaction.Date.Before(end) {
+					continue
+				}
+				sums.addEntry(zero, entry)
+				for _, horizon := range liabilityHorizons {
+					if entry.Time.Before(date.AddDate(horizon.years, horizon.months, 0)) {
+						dues.add(sumKey{zero, entry.Currency, horizon.name, ""}, entry.Amount)
+					}
+				}
+				dues.add(sumKey{periods["month"](entry.Time), entry.Currency, "", ""}, entry.Amount)
+			}
+		}
+	}
+
+	var rows [][]string
+	var currencies []string
+	for _, pc := range sums.periodCurrencies() {
+		currencies = append(currencies, pc.Currency)
+	}
+	assetTops := l.AccountTypes.Roots(ledger.AssetAccount)
+	liabilityTops := l.AccountTypes.Roots(ledger.LiabilityAccount)
+	addSection := func(top string, desc bool) {
+		for _, currency := range currencies {
+			sum, ok := sums[sumKey{zero, currency, top, ""}]
+			if !ok || sum.Total.Sign() == 0 {
+				continue
+			}
+			rows = append(rows, []string{top, ledger.FormatAmount(currency, sum.Total, 2)})
+			for _, kind := range sums.kinds(zero, currency, top, desc) {
+				sum := sums[sumKey{zero, currency, top, kind}]
+				if sum.Total.Sign() == 0 {
+					continue
+				}
+				rows = append(rows, []string{"  " + kind, ledger.FormatAmount(currency, sum.Total, 2)})
+			}
+		}
+	}
+	for _, top := range assetTops {
+		addSection(top, true)
+	}
+	for _, top := range liabilityTops {
+		addSection(top, false)
+	}
+	for _, currency := range currencies {
+		net, _ := sums.total(zero, currency, append(assetTops, liabilityTops...))
+		if net.Sign() != 0 {
+			rows = append(rows, []string{"净资产", ledger.FormatAmount(currency, net, 2)})
+		}
+	}
+	addSection("T+0流动资产", true)
+
+	// liabilities due
+	for _, horizon := range liabilityHorizons {
+		for _, currency := range currencies {
+			due, ok := dues[sumKey{zero, currency, horizon.name, ""}]
+			if !ok || due.Total.Sign() == 0 {
+				continue
+			}
+			rows = append(rows, []string{horizon.name + "负债", ledger.FormatAmount(currency, due.Total, 2)})
+			if assets, ok := sums.total(zero, currency, assetTops); ok {
+				net := new(big.Rat).Add(assets, due.Total)
+				rows = append(rows, []string{horizon.name + "净资产", ledger.FormatAmount(currency, net, 2)})
+			}
+		}
+	}
+	thisMonth := periods["month"](date)
+	last := liabilityHorizons[len(liabilityHorizons)-1]
+	horizonEnd := date.AddDate(last.years, last.months, 0)
+	pcs := dues.periodCurrencies()
+	var dueRows [][]string
+	for i := len(pcs) - 1; i >= 0; i-- {
+		pc := pcs[i]
+		if pc.Period.IsZero() || pc.Period.Before(thisMonth) || !pc.Period.Before(horizonEnd) {
+			continue
+		}
+		due := dues[sumKey{pc.Period, pc.Currency, "", ""}]
+		if due.Total.Sign() == 0 {
+			continue
+		}
+		dueRows = append(dueRows, []string{"  " + pc.Period.Format("2006-01"), ledger.FormatAmount(pc.Currency, due.Total, 2)})
+	}
+	if len(dueRows) > 0 {
+		rows = append(rows, []string{"负债到期", ""})
+		rows = append(rows, dueRows...)
+	}
+
+	if len(rows) == 0 {
+		return
+	}
+	printTable([]string{"account", "amount"}, rows)
+}
+
+// printNetAssetChanges prints net assets at the beginning of each month and the changes between months.
+// Months are those of postings in [begin, end), zero begin or end for no bound
+func printNetAssetChanges(l *ledger.Ledger, begin time.Time, end time.Time) {
+	month := periods["month"]
+	type point struct {
+		date     time.Time
+		currency string
+	}
+	seen := make(map[point]bool)
+	var points []point
+	for _, transaction := range l.Transactions {
+		for _, entry := range transaction.Entries {
+			p := point{month(entry.Time), entry.Currency}
+			if seen[p] || p.date.Before(begin) || !end.IsZero() && !p.date.Before(end) {
+				continue
+			}
+			seen[p] = true
+			points = append(points, p)
+		}
+	}
+	sort.Slice(points, func(i, j int) bool {
+		if !points[i].date.Equal(points[j].date) {
+			return points[i].date.Before(points[j].date)
+		}
+		return points[i].currency < points[j].currency
+	})
+
+	var rows [][]string
+	type last struct {
+		date time.Time
+		net  *big.Rat
+	}
+	lasts := make(map[string]last)
+	for _, p := range points {
+		asset := new(big.Rat)
+		liability := new(big.Rat)
+		for _, transaction := range l.Transactions {
+			for _, entry := range transaction.Entries {
+				if entry.Currency != p.currency {
+					continue
+				}
+				switch l.AccountTypes.Of(entry.Account) {
+				case ledger.AssetAccount:
+					if entry.Time.Before(p.date) {
+						asset.Add(asset, entry.Amount)
+					}
+				case ledger.LiabilityAccount:
+					if transaction.Date.Before(p.date) {
+						liability.Add(liability, entry.Amount)
+					}
+				}
+			}
+		}
+		net := new(big.Rat).Add(asset, liability)
+		var days, change string
+		if prev, ok := lasts[p.currency]; ok {
+			days = fmt.Sprintf("%d", int(p.date.Sub(prev.date).Hours()/24))
+			change = ledger.FormatAmount(p.currency, new(big.Rat).Sub(net, prev.net), 2)
+		}
+		lasts[p.currency] = last{p.date, net}
+		rows = append(rows, []string{
+			p.date.Format("2006-01"),
+			days,
+			ledger.FormatAmount(p.currency, asset, 2),
+			ledger.FormatAmount(p.currency, liability, 2),
+			ledger.FormatAmount(p.currency, net, 2),
+			change,
+		})
+	}
+	if len(rows) == 0 {
+		return
+	}
+	// latest first
+	for i, j := 0, len(rows)-1; i < j; i, j = i+1, j-1 {
+		rows[i], rows[j] = rows[j], rows[i]
+	}
+	printTable([]string{"月份", "日数", "资产", "负债", "净资产", "变动"}, rows)
+}
+
+// printTagTotals prints totals of postings per tag, period and currency.
+// Postings with multiple tags are counted in each tag
+func printTagTotals(l *ledger.Ledger, period func(time.Time) time.Time, layout string) {
+	sums := make(amountSums)
+	for _, transaction := range l.Transactions {
+		for _, entry := range transaction.Entries {
+			for tag := range entry.Tags {
+				sums.add(sumKey{period(entry.Time), entry.Currency, tag, ""}, entry.Amount)
+			}
+		}
+	}
+
+	var rows [][]string
+	for _, pc := range sums.periodCurrencies() {
+		var tags []string
+		for key := range sums {
+			if key.Period.Equal(pc.Period) && key.Currency == pc.Currency {
+				tags = append(tags, key.Top)
+			}
+		}
+		sort.Strings(tags)
+		for _, tag := range tags {
+			sum := sums[sumKey{pc.Period, pc.Currency, tag, ""}]
+			rows = append(rows, []string{
+				pc.Period.Format(layout),
+				strings.TrimSuffix(strings.TrimPrefix(tag, "<"), ">"),
+				ledger.FormatAmount(pc.Currency, sum.Total, 2),
+				ledger.FormatAmount(pc.Currency, sum.Increase, 2),
+				ledger.FormatAmount(pc.Currency, sum.Decrease, 2),
+			})
+		}
+	}
+	if len(rows) == 0 {
+		return
+	}
+	printTable([]string{"period", "tag", "amount", "increase", "decrease"}, rows)
+}
+
+// printPayeeSpending prints expenses per payee, period and currency, payees of larger expenses first.
+// Transactions without payee are not counted
+func printPayeeSpending(l *ledger.Ledger, period func(time.Time) time.Time, layout string) {
+	sums := make(amountSums)
+	counts := make(map[sumKey]int)
+	for _, transaction := range l.Transactions {
+		if transaction.Payee == "" {
+			continue
+		}
+		counted := make(map[sumKey]bool)
+		for _, entry := range transaction.Entries {
+			if l.AccountTypes.Of(entry.Account) != ledger.ExpenseAccount {
+				continue
+			}
+			key := sumKey{period(entry.Time), entry.Currency, transaction.Payee, ""}
+			sums.add(key, entry.Amount)
+			if !counted[key] {
+				counted[key] = true
+				counts[key]++
+			}
+		}
+	}
+
+	var rows [][]string
+	for _, pc := range sums.periodCurrencies() {
+		var payees []string
+		for key := range sums {
+			if key.Period.Equal(pc.Period) && key.Currency == pc.Currency {
+				payees = append(payees, key.Top)
+			}
+		}
+		sort.Slice(payees, func(i, j int) bool {
+			a := sums[sumKey{pc.Period, pc.Currency, payees[i], ""}].Total
+			b := sums[sumKey{pc.Period, pc.Currency, payees[j], ""}].Total
+			if c := a.Cmp(b); c != 0 {
+				return c > 0
+			}
+			return payees[i] < payees[j]
+		})
+		for _, payee := range payees {
+			key := sumKey{pc.Period, pc.Currency, payee, ""}
+			rows = append(rows, []string{
+				pc.Period.Format(layout),
+				payee,
+				ledger.FormatAmount(pc.Currency, sums[key].Total, 2),
+				fmt.Sprintf("%d", counts[key]),
+			})
+		}
+	}
+	if len(rows) == 0 {
+		return
+	}
+	printTable([]string{"period", "payee", "amount", "transactions"}, rows)
+}
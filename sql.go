@@ -1,6 +1,7 @@
 package main
 
 import (
+	"database/sql"
 	"fmt"
 	"io/ioutil"
 	"math/rand"
@@ -8,18 +9,30 @@ import (
 	"os/exec"
 	"os/signal"
 	"path/filepath"
+	"regexp"
 	"strings"
 	"syscall"
 	"time"
 
 	"github.com/jmoiron/sqlx"
 	"github.com/lib/pq"
+	"github.com/reusee/keep/ledger"
 )
 
+// postgresSchemaVersion is the version of tables and views in PostgreSQL.
+// Loading into a schema of another version drops and recreates the tables
+const postgresSchemaVersion = 3
+
+var viewNamePattern = regexp.MustCompile(`create view (\w+) as`)
+
+// sqlInterface loads entries into a temporary PostgreSQL cluster, and runs queries or psql.
+// Returns false if any query failed
 func sqlInterface(
-	rootAccount *Account,
-	transactions []*Transaction,
-) {
+	l *ledger.Ledger,
+	schema string,
+	queries []string,
+	format string,
+) bool {
 
 	execCommand := func(name string, args ...string) *exec.Cmd {
 		if isRoot {
@@ -42,7 +55,7 @@ func sqlInterface(
 		"--username", "foo",
 	).CombinedOutput()
 	ce(err, "%s", out)
-	pt("db dir: %s\n", dbDir)
+	fmt.Fprintf(os.Stderr, "db dir: %s\n", dbDir)
 	defer execCommand("rm", "-rf", dbDir).Run()
 
 	port := 10000 + rand.Intn(50000)
@@ -59,82 +72,19 @@ func sqlInterface(
 	ce(c.Start())
 	defer syscall.Kill(-c.Process.Pid, syscall.SIGKILL)
 	time.Sleep(time.Second)
-	pt("db started at port %d\n", port)
+	fmt.Fprintf(os.Stderr, "db started at port %d\n", port)
 
 	db, err := sqlx.Open("postgres", fmt.Sprintf("postgres://foo@127.0.0.1:%d/template1?sslmode=disable", port))
 	ce(err)
 	defer db.Close()
-	tx := db.MustBegin()
-	_, err = tx.Exec(`
-		CREATE TABLE entries (
-			id bigserial primary key,
-			transaction bigint,
-			transaction_description text,
-      transaction_date timestamp with time zone,
-			date timestamp with time zone,
-			account text[],
-			currency text,
-			amount numeric,
-			description text
-		);
-		CREATE INDEX ON entries(transaction);
-		CREATE INDEX ON entries(date);
-		CREATE INDEX ON entries USING gin((account));
-		`,
-	)
-	ce(err)
+	loadPostgres(db, l, schema)
+	fmt.Fprintf(os.Stderr, "data loaded\n")
 
-	for _, view := range views {
-		_, err = tx.Exec(view)
-		ce(err)
+	if len(queries) > 0 {
+		return runPostgresQueries(db, schema, queries, format)
 	}
 
-	stmt, err := tx.Prepare(pq.CopyIn("entries",
-		"transaction", "transaction_description", "transaction_date",
-		"date", "account",
-		"currency", "amount",
-		"description",
-	))
-	if err != nil {
-		panic(err)
-	}
-	for tid, transaction := range transactions {
-		for _, entry := range transaction.Entries {
-			if _, err := stmt.Exec(
-				tid,
-				transaction.Description,
-				transaction.Date,
-				entry.Time,
-				func() (ret pq.StringArray) {
-					acc := entry.Account
-					for acc != rootAccount {
-						ret = append(ret, acc.Name)
-						acc = acc.Parent
-					}
-					for i := len(ret)/2 - 1; i >= 0; i-- {
-						j := len(ret) - 1 - i
-						ret[i], ret[j] = ret[j], ret[i]
-					}
-					return
-				}(),
-				entry.Currency,
-				entry.Amount.FloatString(3),
-				entry.Description,
-			); err != nil {
-				panic(err)
-			}
-		}
-	}
-	if _, err := stmt.Exec(); err != nil {
-		panic(err)
-	}
-	if err := stmt.Close(); err != nil {
-		panic(err)
-	}
-	ce(tx.Commit())
-	pt("data loaded\n")
-
-	sigs := make(chan os.Signal)
+	sigs := make(chan os.Signal, 1)
 	go func() {
 		for {
 			<-sigs
@@ -143,15 +93,222 @@ func sqlInterface(
 	signal.Notify(sigs, os.Interrupt)
 
 	psql := exec.Command("psql", fmt.Sprintf("postgres://foo@127.0.0.1:%d/template1", port))
+	psql.Env = append(os.Environ(), "PGOPTIONS=-c search_path="+pq.QuoteIdentifier(schema))
 	psql.Stdout = os.Stdout
 	psql.Stdin = os.Stdin
 	psql.Stderr = os.Stderr
 	ce(psql.Run())
+	return true
 }
 
-var views = []string{
-	// things
-	`
+// dsnInterface loads entries into the PostgreSQL database of dsn, and runs queries or shows a query prompt.
+// Data is kept in the database after exit. Returns false if any query failed
+func dsnInterface(l *ledger.Ledger, dsn string, schema string, queries []string, format string) bool {
+	db, err := sqlx.Open("postgres", dsn)
+	ce(err)
+	defer db.Close()
+	loadPostgres(db, l, schema)
+	fmt.Fprintf(os.Stderr, "data loaded into schema %s\n", schema)
+	return runPostgresQueries(db, schema, queries, format)
+}
+
+func runPostgresQueries(db *sqlx.DB, schema string, queries []string, format string) bool {
+	// search_path is per connection
+	db.SetMaxOpenConns(1)
+	_, err := db.Exec(`set search_path to ` + pq.QuoteIdentifier(schema))
+	ce(err)
+	return runQueries(db, queries, format)
+}
+
+// loadPostgres creates tables and views in schema if not exist, and replaces loaded entries and prices with those of l.
+// Tables of other schema versions are dropped and recreated.
+// Schemas not loaded by keep before are refused if they have tables or views with the names of keep's
+func loadPostgres(db *sqlx.DB, l *ledger.Ledger, schema string) {
+	tx := db.MustBegin()
+	defer tx.Rollback()
+	exec := func(query string, args ...any) {
+		_, err := tx.Exec(query, args...)
+		ce(err, "%s", query)
+	}
+
+	// serialize concurrent loads into the same schema
+	exec(`select pg_advisory_xact_lock(hashtext($1))`, schema)
+	exec(`create schema if not exists ` + pq.QuoteIdentifier(schema))
+	exec(`set local search_path to ` + pq.QuoteIdentifier(schema))
+
+	// objects of all versions
+	names := []string{"entries", "prices", "transactions", "accounts", "postings", "tags", "metadata"}
+	for _, view := range sqlViews() {
+		if m := viewNamePattern.FindStringSubmatch(view); m != nil {
+			names = append(names, m[1])
+		}
+	}
+	tableTypes := make(map[string]string)
+	for _, name := range names {
+		var tableType string
+		err := tx.Get(&tableType, `select table_type from information_schema.tables where table_schema = $1 and table_name = $2`, schema, name)
+		if err == sql.ErrNoRows {
+			continue
+		}
+		ce(err)
+		tableTypes[name] = tableType
+	}
+
+	var version int
+	var loaded bool
+	ce(tx.Get(&loaded, `select exists (select 1 from information_schema.tables where table_schema = $1 and table_name = 'schema_version')`, schema))
+	if loaded {
+		ce(tx.Get(&version, `select coalesce(max(version), 0) from schema_version`))
+		loaded = version > 0
+	}
+	if !loaded && len(tableTypes) > 0 {
+		// not created by keep
+		var existing []string
+		for _, name := range names {
+			if _, ok := tableTypes[name]; ok {
+				existing = append(existing, name)
+			}
+		}
+		ce(me(nil, "schema %s has tables or views not created by keep: %s", schema, strings.Join(existing, ", ")))
+	}
+	exec(`create table if not exists schema_version (version integer not null)`)
+
+	if version != postgresSchemaVersion {
+		for _, name := range names {
+			tableType, ok := tableTypes[name]
+			if !ok {
+				continue
+			}
+			if tableType == "VIEW" {
+				exec(`drop view if exists ` + pq.QuoteIdentifier(name) + ` cascade`)
+			} else {
+				exec(`drop table if exists ` + pq.QuoteIdentifier(name) + ` cascade`)
+			}
+		}
+		exec(`
+			CREATE TABLE transactions (
+				id bigint primary key,
+				date timestamp with time zone,
+				payee text,
+				description text
+			);
+			CREATE INDEX ON transactions(date);
+			CREATE INDEX ON transactions(payee);
+			CREATE TABLE accounts (
+				id bigint primary key,
+				parent_id bigint references accounts(id),
+				name text,
+				path text[],
+				open_date timestamp with time zone,
+				close_date timestamp with time zone
+			);
+			CREATE INDEX ON accounts(parent_id);
+			CREATE INDEX ON accounts USING gin((path));
+			CREATE TABLE postings (
+				id bigint primary key,
+				transaction_id bigint references transactions(id),
+				account_id bigint references accounts(id),
+				date timestamp with time zone,
+				currency text,
+				amount numeric,
+				description text
+			);
+			CREATE INDEX ON postings(transaction_id);
+			CREATE INDEX ON postings(account_id);
+			CREATE INDEX ON postings(date);
+			CREATE TABLE tags (
+				posting_id bigint references postings(id),
+				tag text
+			);
+			CREATE INDEX ON tags(posting_id);
+			CREATE INDEX ON tags(tag);
+			CREATE TABLE metadata (
+				transaction_id bigint references transactions(id),
+				posting_id bigint references postings(id),
+				key text,
+				value text
+			);
+			CREATE INDEX ON metadata(transaction_id);
+			CREATE INDEX ON metadata(posting_id);
+			CREATE INDEX ON metadata(key);
+			CREATE TABLE prices (
+				date timestamp with time zone,
+				commodity text,
+				currency text,
+				amount numeric
+			);
+			CREATE INDEX ON prices(commodity, date);
+		`)
+		exec(`delete from schema_version`)
+		exec(`insert into schema_version (version) values ($1)`, postgresSchemaVersion)
+	} else {
+		exec(`truncate metadata, tags, postings, accounts, transactions, prices`)
+	}
+
+	// views are replaced to keep objects depending on them
+	for _, view := range sqlViews() {
+		exec(strings.Replace(view, "create view", "create or replace view", 1))
+	}
+
+	for _, table := range sqlTables(l) {
+		stmt, err := tx.Prepare(pq.CopyInSchema(schema, table.name, table.columns...))
+		ce(err)
+		for _, row := range table.rows {
+			_, err := stmt.Exec(sqlValues(
+				row,
+				func(path []string) any {
+					return pq.StringArray(path)
+				},
+				func(t time.Time) any {
+					return t
+				},
+			)...)
+			ce(err)
+		}
+		_, err = stmt.Exec()
+		ce(err)
+		ce(stmt.Close())
+	}
+
+	ce(tx.Commit())
+}
+
+// sqlViews returns the views of the SQL mode for PostgreSQL
+func sqlViews() []string {
+	return []string{
+		// entries joins postings with transactions and accounts, views below are defined on it
+		`
+	create view entries as
+	select
+	p.id
+	,p.transaction_id as transaction
+	,t.payee
+	,t.description as transaction_description
+	,t.date as transaction_date
+	,p.date
+	,a.path as account
+	,p.currency
+	,p.amount
+	,p.description
+	,array(select tag from tags where posting_id = p.id order by tag) as tags
+	,coalesce((
+		select jsonb_object_agg(m.key, m.value)
+		from metadata m
+		where m.transaction_id = p.transaction_id
+		and (
+			m.posting_id = p.id
+			or m.posting_id is null and not exists (
+				select 1 from metadata m2 where m2.posting_id = p.id and m2.key = m.key
+			)
+		)
+	), '{}') as metadata
+	from postings p
+	join transactions t on t.id = p.transaction_id
+	join accounts a on a.id = p.account_id
+	`,
+
+		// things
+		`
 	create view things as 
 	select * from (
 		select max(date) as date, max(description) as description, max(kinds) as kinds
@@ -165,20 +322,8 @@ var views = []string{
 			,currency
 			,sum(amount) as amount
 			from entries
-			where account[1] = '支出'
-			and account[2] in (
-				''
-				` + func() string {
-		var b strings.Builder
-		for kind := range itemKinds {
-			b.WriteString(",'" + kind + "'")
-		}
-		for kind := range consumableKinds {
-			b.WriteString(",'" + kind + "'")
-		}
-		return b.String()
-	}() + `
-			)
+			where account[1] ` + sqlRootIn(ledger.ExpenseAccount) + `
+			and account[2] in (` + sqlQuoteList(append([]string{""}, thingKinds()...)) + `)
 			group by transaction, currency
 		) t0
 		group by transaction
@@ -186,39 +331,34 @@ var views = []string{
 	order by date desc, description
 	`,
 
-	// consumables
-	`
+		// consumables
+		`
 	create view consumables as 
 	select distinct on (date, transaction)
 	date, transaction_description
 	from entries
-	where account[1] = '支出'
-	and account[2] in (
-		'饮食',
-		'消耗品',
-		'药物',
-		'保健品'
-	)
+	where account[1] ` + sqlRootIn(ledger.ExpenseAccount) + `
+	and account[2] in (` + sqlQuoteList(append([]string{""}, consumablesViewKinds...)) + `)
 	order by date desc, transaction
 	`,
 
-	// yearly
-	"create view yearly as" + intervalStat("date_trunc('year', date)"),
+		// yearly
+		"create view yearly as" + intervalStat("date_trunc('year', date)"),
 
-	// seasonally
-	"create view seasonally as" + intervalStat("date_trunc('year', date) + interval '3 month' * (extract(month from date)::int / 3)"),
+		// seasonally
+		"create view seasonally as" + intervalStat("date_trunc('year', date) + interval '3 month' * (extract(month from date)::int / 3)"),
 
-	// monthly
-	"create view monthly as" + intervalStat("date_trunc('month', date)"),
+		// monthly
+		"create view monthly as" + intervalStat("date_trunc('month', date)"),
 
-	// weekly
-	"create view weekly as" + intervalStat("date_trunc('week', date)"),
+		// weekly
+		"create view weekly as" + intervalStat("date_trunc('week', date)"),
 
-	// daily
-	"create view daily as" + intervalStat("date_trunc('day', date)"),
+		// daily
+		"create view daily as" + intervalStat("date_trunc('day', date)"),
 
-	// this year expenses
-	`
+		// this year expenses
+		`
 	create view yearly_expenses as
 	select 
 	extract(year from date) as year, currency, sum(amount), account[2], 
@@ -233,13 +373,13 @@ var views = []string{
 	)) 
 	from entries
 	where true
-	and account[1] = '支出' 
+	and account[1] ` + sqlRootIn(ledger.ExpenseAccount) + ` 
 	group by extract(year from date), account[2], currency 
 	order by extract(year from date) desc, sum desc
 	`,
 
-	// balance sheet
-	`
+		// balance sheet
+		`
 	create view balance_sheet as
 	select
 
@@ -247,7 +387,7 @@ var views = []string{
 		select string_agg(currency || amount, E'\n') from (
 			select sum(amount) as amount, currency
 			from entries
-			where account[1] = '资产'
+			where account[1] ` + sqlRootIn(ledger.AssetAccount) + `
 			group by currency
 		) t0
 		where amount <> 0
@@ -261,7 +401,7 @@ var views = []string{
 		from (
 			select sum(amount) as amount, currency, account[2] as kind
 			from entries
-			where account[1] = '资产'
+			where account[1] ` + sqlRootIn(ledger.AssetAccount) + `
 			group by currency, account[2]
 		) t0
 		where amount <> 0
@@ -272,7 +412,7 @@ var views = []string{
 		select string_agg(currency || amount, E'\n') from (
 			select sum(amount) as amount, currency
 			from entries
-			where account[1] = '负债'
+			where account[1] ` + sqlRootIn(ledger.LiabilityAccount) + `
 			group by currency
 		) t0
 		where amount <> 0
@@ -286,7 +426,7 @@ var views = []string{
 		from (
 			select sum(amount) as amount, currency, account[2] as kind
 			from entries
-			where account[1] = '负债'
+			where account[1] ` + sqlRootIn(ledger.LiabilityAccount) + `
 			group by currency, account[2]
 		) t0
 		where amount <> 0
@@ -298,11 +438,11 @@ var views = []string{
 			select sum(amount) + (
 				select sum(amount) 
 				from entries e
-				where account[1] = '负债'
+				where account[1] ` + sqlRootIn(ledger.LiabilityAccount) + `
 				and currency = entries.currency
 			) as amount, currency
 			from entries
-			where account[1] = '资产'
+			where account[1] ` + sqlRootIn(ledger.AssetAccount) + `
 			group by currency
 		) t0
 		where amount <> 0
@@ -310,18 +450,18 @@ var views = []string{
 	AS 净资产
 
 	` + func() string {
-		buf := new(strings.Builder)
-		for _, args := range [][]string{
-			{"1 year", "一年"},
-			{"3 year", "三年"},
-		} {
-			buf.WriteString(`
+			buf := new(strings.Builder)
+			for _, args := range [][]string{
+				{"1 year", "一年"},
+				{"3 year", "三年"},
+			} {
+				buf.WriteString(`
 	,'负债：'
 	|| (
 		select string_agg(currency || amount, E'\n') from (
 			select sum(amount) as amount, currency
 			from entries
-			where account[1] = '负债'
+			where account[1] ` + sqlRootIn(ledger.LiabilityAccount) + `
 			and date < now() + interval '` + args[0] + `'
 			group by currency
 		) t0
@@ -334,12 +474,12 @@ var views = []string{
 			select sum(amount) + (
 				select sum(amount) 
 				from entries e
-				where account[1] = '负债'
+				where account[1] ` + sqlRootIn(ledger.LiabilityAccount) + `
 				and currency = entries.currency
 				and date < now() + interval '` + args[0] + `'
 			) as amount, currency
 			from entries
-			where account[1] = '资产'
+			where account[1] ` + sqlRootIn(ledger.AssetAccount) + `
 			group by currency
 		) t0
 		where amount <> 0
@@ -355,7 +495,7 @@ var views = []string{
 		from (
 			select sum(amount) as amount, currency, account[2] as kind
 			from entries
-			where account[1] = '负债'
+			where account[1] ` + sqlRootIn(ledger.LiabilityAccount) + `
 			and date < now() + interval '` + args[0] + `'
 			group by currency, account[2]
 		) t0
@@ -374,7 +514,7 @@ var views = []string{
 		from (
 			select sum(amount) as amount, currency, date_trunc('month', date) as month
 			from entries
-			where account[1] = '负债'
+			where account[1] ` + sqlRootIn(ledger.LiabilityAccount) + `
 			and date < now() + interval '` + args[0] + `'
 			group by date_trunc('month', date), currency
 		) t0
@@ -382,24 +522,23 @@ var views = []string{
 	)
 	AS ` + args[1] + `预算
 			`)
-		}
-		return buf.String()
-	}() + `
+			}
+			return buf.String()
+		}() + `
 
 	` + func() string {
-		cond := `
+			cond := `
 		and true in (
 			false
-			,account[1] = '资产' and account[2] = '工行'
-			,account[1] = '资产' and account[2] = '建行'
-			,account[1] = '资产' and account[2] = '中信'
-			,account[1] = '资产' and account[2] = '兴业'
-			,account[1] = '资产' and account[2] = '现金'
-			,account[1] = '资产' and account[2] = '微信钱包'
-			,account[1] = '资产' and account[2] = '京东金库'
+		`
+			for _, name := range liquidAssets {
+				cond += `
+			,account[1] ` + sqlRootIn(ledger.AssetAccount) + ` and account[2] = ` + sqlQuote(name)
+			}
+			cond += `
 		)
 		`
-		return `
+			return `
 		,(
 			select string_agg(currency || amount, E'\n') from (
 				select sum(amount) as amount, currency
@@ -428,13 +567,13 @@ var views = []string{
 		)
 		AS "T+0流动资产"
 		`
-	}() + `
+		}() + `
 
 	,(
 		select string_agg(currency || amount, E'\n') from (
 			select sum(amount) as amount, currency
 			from entries
-			where account[1] = '负债'
+			where account[1] ` + sqlRootIn(ledger.LiabilityAccount) + `
 			and date < now() + interval '1 month'
 			group by currency
 		) t0
@@ -449,7 +588,7 @@ var views = []string{
 		from (
 			select sum(amount) as amount, currency, account[2] as kind
 			from entries
-			where account[1] = '负债'
+			where account[1] ` + sqlRootIn(ledger.LiabilityAccount) + `
 			and date < now() + interval '1 month'
 			group by currency, account[2]
 		) t0
@@ -459,8 +598,8 @@ var views = []string{
 
 	`,
 
-	// net_asset_changes
-	`
+		// net_asset_changes
+		`
 	create view net_asset_changes as
 	select 
 	to_char(d, 'YYYY-MM') AS 月份,
@@ -477,14 +616,14 @@ var views = []string{
 		COALESCE((
 			select sum(amount)
 			from entries
-			where account[1] = '资产'
+			where account[1] ` + sqlRootIn(ledger.AssetAccount) + `
 			and currency = c
 			and date < d
 		), 0) as asset,
 		COALESCE((
 			select sum(amount)
 			from entries
-			where account[1] = '负债'
+			where account[1] ` + sqlRootIn(ledger.LiabilityAccount) + `
 			and currency = c
 			and transaction_date < d
 		), 0) as liability
@@ -497,8 +636,8 @@ var views = []string{
 	order by d desc
 	`,
 
-	// assurance
-	`
+		// assurance
+		`
 	create view assurance as
 	select 
 	year, d,  sum(amount)
@@ -512,14 +651,14 @@ var views = []string{
 			and account[1] = '保险' and account[2] = '生效'
 		) as d
 		from entries
-		where account[1] = '支出' and account[2] = '保险'
+		where account[1] ` + sqlRootIn(ledger.ExpenseAccount) + ` and account[2] = '保险'
 	) t0
 	group by d, year
 	order by year asc, sum(amount) desc
 	`,
 
-	// foods
-	`
+		// foods
+		`
 	create view foods as
 	select account, amount
 	from (
@@ -538,7 +677,7 @@ var views = []string{
 			where transaction in (
 				select transaction
 				from entries
-				where account[1] = '支出'
+				where account[1] ` + sqlRootIn(ledger.ExpenseAccount) + `
 				and account[2] = '饮食'
 			)
 			and account[1] = '消耗品购买'
@@ -549,8 +688,8 @@ var views = []string{
 	order by date asc
 	`,
 
-	// funds
-	`
+		// funds
+		`
 	create view funds as
 	select * 
 	,(account[5]::numeric * (
@@ -565,7 +704,7 @@ var views = []string{
 		,account
 		,sum(amount) as amount
 		from entries
-		where account[1] = '资产'
+		where account[1] ` + sqlRootIn(ledger.AssetAccount) + `
 		and account[2] ~ '.*股基.*'
 		and account[5] ~ '[0-9]+\.[0-9]+'
 		group by account
@@ -574,8 +713,8 @@ var views = []string{
 	order by account, target
 	`,
 
-	// equity
-	`
+		// equity
+		`
 	create view equity as
 	select 
 	distinct on (date)
@@ -587,14 +726,15 @@ var views = []string{
 		sum(amount) over (order by transaction asc)
 		from entries
 		where date < now()
-		and account[1] = '资产'
+		and account[1] ` + sqlRootIn(ledger.AssetAccount) + `
 		and currency = '￥'
 		order by transaction desc
 	) t0
 	order by date desc, transaction desc
 	`,
 
-	//
+		//
+	}
 }
 
 func intervalStat(groupBy string) string {
@@ -603,7 +743,7 @@ func intervalStat(groupBy string) string {
 	to_char(` + groupBy + `, 'YYYY-MM-DD') as span,
 
 	COALESCE(
-		'支出' || currency || (sum(amount) filter (where account[1] = '支出'))::numeric(20,2)::text 
+		'支出' || currency || (sum(amount) filter (where account[1] ` + sqlRootIn(ledger.ExpenseAccount) + `))::numeric(20,2)::text 
 		|| E'：\n'
 		|| (
 			select string_agg(
@@ -613,7 +753,7 @@ func intervalStat(groupBy string) string {
 			) from (
 				select account[2] as account, currency, sum(amount) as amount
 				from unnest(
-					array_agg(id) filter (where account[1] = '支出')
+					array_agg(id) filter (where account[1] ` + sqlRootIn(ledger.ExpenseAccount) + `)
 				) as id
 				join entries e2 using (id)
 				group by account[2], currency
@@ -623,7 +763,7 @@ func intervalStat(groupBy string) string {
 	) || E'\n' as expenses,
 
 	COALESCE(
-		'收入' || currency || (sum(amount) filter (where account[1] = '收入'))::numeric(20,2)::text 
+		'收入' || currency || (sum(amount) filter (where account[1] ` + sqlRootIn(ledger.IncomeAccount) + `))::numeric(20,2)::text 
 		|| E'：\n'
 		|| (
 			select string_agg(
@@ -633,7 +773,7 @@ func intervalStat(groupBy string) string {
 			) from (
 				select account[2] as account, currency, sum(amount) as amount
 				from unnest(
-					array_agg(id) filter (where account[1] = '收入')
+					array_agg(id) filter (where account[1] ` + sqlRootIn(ledger.IncomeAccount) + `)
 				) as id
 				join entries e2 using (id)
 				group by account[2], currency
@@ -644,9 +784,9 @@ func intervalStat(groupBy string) string {
 
 	COALESCE(
 		'净资产' || currency || (
-			-sum(amount) filter (where account[1] = '收入')
+			-sum(amount) filter (where account[1] ` + sqlRootIn(ledger.IncomeAccount) + `)
 			-
-			sum(amount) filter (where account[1] = '支出')
+			sum(amount) filter (where account[1] ` + sqlRootIn(ledger.ExpenseAccount) + `)
 		)::numeric(20,2)::text,
 		'-'
 	) || E'\n' as net_income,
@@ -666,7 +806,7 @@ func intervalStat(groupBy string) string {
 				COALESCE(sum(amount) filter (where amount >= 0), 0) as pos_amount,
 				COALESCE(-sum(amount) filter (where amount < 0), 0) as neg_amount
 				from unnest(
-					array_agg(id) filter (where account[1] = '资产')
+					array_agg(id) filter (where account[1] ` + sqlRootIn(ledger.AssetAccount) + `)
 				) as id
 				join entries e2 using (id)
 				group by account[2], currency
@@ -690,7 +830,7 @@ func intervalStat(groupBy string) string {
 				COALESCE(sum(amount) filter (where amount >= 0), 0) as pos_amount,
 				COALESCE(-sum(amount) filter (where amount < 0), 0) as neg_amount
 				from unnest(
-					array_agg(id) filter (where account[1] = '负债')
+					array_agg(id) filter (where account[1] ` + sqlRootIn(ledger.LiabilityAccount) + `)
 				) as id
 				join entries e2 using (id)
 				group by account[2], currency
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/reusee/keep/ledger"
+)
+
+// sqlTable is the rows of a table in the SQL mode
+type sqlTable struct {
+	name    string
+	columns []string
+	// rows hold values of go types; account paths are []string, dates are time.Time, zero for NULL
+	rows [][]any
+}
+
+// sqlTables returns rows of the normalized tables, parents before children.
+// Ids of transactions, accounts and postings start from 1
+func sqlTables(l *ledger.Ledger) []*sqlTable {
+	transactions := &sqlTable{
+		name:    "transactions",
+		columns: []string{"id", "date", "payee", "description"},
+	}
+	accounts := &sqlTable{
+		name:    "accounts",
+		columns: []string{"id", "parent_id", "name", "path", "open_date", "close_date"},
+	}
+	postings := &sqlTable{
+		name:    "postings",
+		columns: []string{"id", "transaction_id", "account_id", "date", "currency", "amount", "description"},
+	}
+	tags := &sqlTable{
+		name:    "tags",
+		columns: []string{"posting_id", "tag"},
+	}
+	metadata := &sqlTable{
+		name:    "metadata",
+		columns: []string{"transaction_id", "posting_id", "key", "value"},
+	}
+	prices := &sqlTable{
+		name:    "prices",
+		columns: []string{"date", "commodity", "currency", "amount"},
+	}
+
+	accountIDs := make(map[*ledger.Account]int)
+	var addAccount func(account *ledger.Account)
+	addAccount = func(account *ledger.Account) {
+		if account.Parent != nil {
+			id := len(accountIDs) + 1
+			accountIDs[account] = id
+			var parentID any
+			if account.Parent.Parent != nil {
+				parentID = accountIDs[account.Parent]
+			}
+			accounts.rows = append(accounts.rows, []any{
+				id,
+				parentID,
+				account.Name,
+				account.Path(),
+				account.OpenDate,
+				account.CloseDate,
+			})
+		}
+		var names []string
+		for name := range account.Subs {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			addAccount(account.Subs[name])
+		}
+	}
+	addAccount(l.Root)
+
+	for i, transaction := range l.Transactions {
+		tid := i + 1
+		var payee any
+		if transaction.Payee != "" {
+			payee = transaction.Payee
+		}
+		transactions.rows = append(transactions.rows, []any{
+			tid,
+			transaction.Date,
+			payee,
+			transaction.Description,
+		})
+		addMetadata := func(postingID any, m ledger.Metadata) {
+			var keys []string
+			for key := range m {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+			for _, key := range keys {
+				metadata.rows = append(metadata.rows, []any{tid, postingID, key, m[key]})
+			}
+		}
+		addMetadata(nil, transaction.Metadata)
+		for _, entry := range transaction.Entries {
+			pid := len(postings.rows) + 1
+			postings.rows = append(postings.rows, []any{
+				pid,
+				tid,
+				accountIDs[entry.Account],
+				entry.Time,
+				entry.Currency,
+				ledger.DecimalString(entry.Amount),
+				entry.Description,
+			})
+			var names []string
+			for tag := range entry.Tags {
+				names = append(names, tag)
+			}
+			sort.Strings(names)
+			for _, tag := range names {
+				tags.rows = append(tags.rows, []any{
+					pid,
+					strings.TrimSuffix(strings.TrimPrefix(tag, "<"), ">"),
+				})
+			}
+			addMetadata(pid, entry.Metadata)
+		}
+	}
+
+	for _, history := range l.Prices {
+		for _, price := range history {
+			prices.rows = append(prices.rows, []any{
+				price.Date,
+				price.Commodity,
+				price.Currency,
+				ledger.DecimalString(price.Amount),
+			})
+		}
+	}
+
+	return []*sqlTable{
+		transactions,
+		accounts,
+		postings,
+		tags,
+		metadata,
+		prices,
+	}
+}
+
+// sqlValues returns row with account paths and dates converted by path and date functions, zero dates to NULL
+func sqlValues(row []any, path func([]string) any, date func(time.Time) any) []any {
+	ret := make([]any, len(row))
+	for i, value := range row {
+		switch value := value.(type) {
+		case []string:
+			ret[i] = path(value)
+		case time.Time:
+			if value.IsZero() {
+				ret[i] = nil
+			} else {
+				ret[i] = date(value)
+			}
+		default:
+			ret[i] = value
+		}
+	}
+	return ret
+}
+
+// sqlRootIn returns an in condition matching the root account names of type t
+func sqlRootIn(t ledger.AccountType) string {
+	roots := accountTypes.Roots(t)
+	if len(roots) == 0 {
+		return "in (NULL)"
+	}
+	return "in (" + sqlQuoteList(roots) + ")"
+}
+
+// sqlQuote returns s as a SQL string literal
+func sqlQuote(s string) string {
+	return "'" + strings.Replace(s, "'", "''", -1) + "'"
+}
+
+// sqlQuoteList returns values as comma-separated SQL string literals
+func sqlQuoteList(values []string) string {
+	quoted := make([]string, 0, len(values))
+	for _, value := range values {
+		quoted = append(quoted, sqlQuote(value))
+	}
+	return strings.Join(quoted, ", ")
+}
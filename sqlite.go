@@ -0,0 +1,594 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/mattn/go-sqlite3"
+	"github.com/reusee/keep/ledger"
+)
+
+func init() {
+	// sqlite3 with functions used by views
+	sql.Register("sqlite3_keep", &sqlite3.SQLiteDriver{
+		ConnectHook: func(conn *sqlite3.SQLiteConn) error {
+			return conn.RegisterFunc("power", math.Pow, true)
+		},
+	})
+}
+
+// sqliteInterface loads entries into a SQLite database at path, or in memory if path is empty, and runs queries or shows a query prompt.
+// Returns false if any query failed
+func sqliteInterface(l *ledger.Ledger, path string, queries []string, format string) bool {
+	dsn := path
+	if dsn == "" {
+		dsn = ":memory:"
+	}
+	db, err := sqlx.Open("sqlite3_keep", dsn)
+	ce(err)
+	defer db.Close()
+	// an in-memory database is private to its connection
+	db.SetMaxOpenConns(1)
+
+	loadSQLite(db, l)
+	if path != "" {
+		fmt.Fprintf(os.Stderr, "data loaded into %s\n", path)
+	} else {
+		fmt.Fprintf(os.Stderr, "data loaded\n")
+	}
+
+	return runQueries(db, queries, format)
+}
+
+// loadSQLite creates tables and views in db and loads entries and prices of l, replacing previously loaded ones.
+// Only objects created by keep are dropped, databases with other tables are refused
+func loadSQLite(db *sqlx.DB, l *ledger.Ledger) {
+	tx := db.MustBegin()
+	defer tx.Rollback()
+
+	// objects of all versions
+	owned := map[string]bool{
+		"entries":      true,
+		"prices":       true,
+		"transactions": true,
+		"accounts":     true,
+		"postings":     true,
+		"tags":         true,
+		"metadata":     true,
+	}
+	for _, view := range sqliteViews() {
+		if m := viewNamePattern.FindStringSubmatch(view); m != nil {
+			owned[m[1]] = true
+		}
+	}
+	rows, err := tx.Query(`select type, name from sqlite_master where type in ('view', 'table') and name not like 'sqlite_%' order by type desc`)
+	ce(err)
+	var drops, others []string
+	for rows.Next() {
+		var typ, name string
+		ce(rows.Scan(&typ, &name))
+		if owned[name] {
+			// views before tables
+			drops = append(drops, fmt.Sprintf(`drop %s if exists "%s"`, typ, name))
+		} else if typ == "table" {
+			others = append(others, name)
+		}
+	}
+	ce(rows.Err())
+	ce(rows.Close())
+	if len(others) > 0 {
+		ce(me(nil, "database has tables not created by keep: %s", strings.Join(others, ", ")))
+	}
+	for _, drop := range drops {
+		_, err := tx.Exec(drop)
+		ce(err)
+	}
+
+	_, err = tx.Exec(`
+		CREATE TABLE transactions (
+			id integer primary key,
+			date text,
+			payee text,
+			description text
+		);
+		CREATE INDEX transactions_date ON transactions(date);
+		CREATE INDEX transactions_payee ON transactions(payee);
+		CREATE TABLE accounts (
+			id integer primary key,
+			parent_id integer references accounts(id),
+			name text,
+			path json,
+			open_date text,
+			close_date text
+		);
+		CREATE INDEX accounts_parent_id ON accounts(parent_id);
+		CREATE TABLE postings (
+			id integer primary key,
+			transaction_id integer references transactions(id),
+			account_id integer references accounts(id),
+			date text,
+			currency text,
+			amount numeric,
+			description text
+		);
+		CREATE INDEX postings_transaction_id ON postings(transaction_id);
+		CREATE INDEX postings_account_id ON postings(account_id);
+		CREATE INDEX postings_date ON postings(date);
+		CREATE TABLE tags (
+			posting_id integer references postings(id),
+			tag text
+		);
+		CREATE INDEX tags_posting_id ON tags(posting_id);
+		CREATE INDEX tags_tag ON tags(tag);
+		CREATE TABLE metadata (
+			transaction_id integer references transactions(id),
+			posting_id integer references postings(id),
+			key text,
+			value text
+		);
+		CREATE INDEX metadata_transaction_id ON metadata(transaction_id);
+		CREATE INDEX metadata_posting_id ON metadata(posting_id);
+		CREATE INDEX metadata_key ON metadata(key);
+		CREATE TABLE prices (
+			date text,
+			commodity text,
+			currency text,
+			amount numeric
+		);
+		CREATE INDEX prices_commodity_date ON prices(commodity, date);
+	`)
+	ce(err)
+
+	for _, view := range sqliteViews() {
+		_, err = tx.Exec(view)
+		ce(err, "%s", view)
+	}
+
+	for _, table := range sqlTables(l) {
+		stmt, err := tx.Prepare(`insert into ` + table.name + ` (` + strings.Join(table.columns, ", ") +
+			`) values (?` + strings.Repeat(", ?", len(table.columns)-1) + `)`)
+		ce(err)
+		for _, row := range table.rows {
+			_, err := stmt.Exec(sqlValues(
+				row,
+				func(path []string) any {
+					bs, err := json.Marshal(path)
+					ce(err)
+					return string(bs)
+				},
+				func(t time.Time) any {
+					return t.Format("2006-01-02")
+				},
+			)...)
+			ce(err)
+		}
+		ce(stmt.Close())
+	}
+
+	ce(tx.Commit())
+}
+
+// sqliteDecimal formats a numeric SQL expression with two decimal digits, NULL is kept
+func sqliteDecimal(expr string) string {
+	return `(case when (` + expr + `) is null then null else printf('%.2f', ` + expr + `) end)`
+}
+
+// sqliteSums returns an expression of the sums of amounts of entries matching cond, per currency
+func sqliteSums(cond string) string {
+	return `(
+		select group_concat(currency || ` + sqliteDecimal("amount") + `, char(10)) from (
+			select sum(amount) as amount, currency
+			from entries
+			where ` + cond + `
+			group by currency
+		) t0
+		where amount <> 0
+	)`
+}
+
+// sqliteKindSums returns an expression of the sums of amounts of entries matching cond, per second level account and currency
+func sqliteKindSums(cond string, order string) string {
+	return `(
+		select group_concat(kind || ' ' || currency || ` + sqliteDecimal("amount") + `, char(10)) from (
+			select sum(amount) as amount, currency, account ->> 1 as kind
+			from entries
+			where ` + cond + `
+			group by currency, account ->> 1
+			order by amount ` + order + `
+		) t0
+		where amount <> 0
+	)`
+}
+
+// sqliteNetAssets returns an expression of assets plus liabilities per currency, liabilities matching cond
+func sqliteNetAssets(cond string) string {
+	return `(
+		select group_concat(currency || ` + sqliteDecimal("amount") + `, char(10)) from (
+			select sum(amount) + coalesce((
+				select sum(amount)
+				from entries e
+				where account ->> 0 ` + sqlRootIn(ledger.LiabilityAccount) + `
+				and e.currency = entries.currency
+				and ` + cond + `
+			), 0) as amount, currency
+			from entries
+			where account ->> 0 ` + sqlRootIn(ledger.AssetAccount) + `
+			group by currency
+		) t0
+		where amount <> 0
+	)`
+}
+
+func sqliteIntervalStat(groupBy string) string {
+	kinds := func(t ledger.AccountType, line string, order string) string {
+		return `(
+			select group_concat(line, char(10)) from (
+				select ` + line + ` as line
+				from k k2
+				where k2.span = k.span
+				and k2.currency = k.currency
+				and k2.top ` + sqlRootIn(t) + `
+				and k2.kind is not null
+				order by amount ` + order + `
+			) t0
+		)`
+	}
+	return `
+	with e as (
+		select *, ` + groupBy + ` as span
+		from entries
+	), k as (
+		select span, currency, account ->> 0 as top, account ->> 1 as kind,
+		sum(amount) as amount,
+		coalesce(sum(amount) filter (where amount >= 0), 0) as pos_amount,
+		coalesce(-sum(amount) filter (where amount < 0), 0) as neg_amount
+		from e
+		group by span, currency, top, kind
+	)
+	select
+	span,
+
+	coalesce(
+		'支出' || currency || ` + sqliteDecimal("sum(amount) filter (where top "+sqlRootIn(ledger.ExpenseAccount)+")") + `
+		|| '：' || char(10)
+		|| ` + kinds(ledger.ExpenseAccount, "currency || "+sqliteDecimal("amount")+" || ' ' || kind", "desc") + `,
+		'-'
+	) || char(10) as expenses,
+
+	coalesce(
+		'收入' || currency || ` + sqliteDecimal("sum(amount) filter (where top "+sqlRootIn(ledger.IncomeAccount)+")") + `
+		|| '：' || char(10)
+		|| ` + kinds(ledger.IncomeAccount, "currency || "+sqliteDecimal("amount")+" || ' ' || kind", "asc") + `,
+		'-'
+	) || char(10) as income,
+
+	coalesce(
+		'净资产' || currency || ` + sqliteDecimal(`
+			-sum(amount) filter (where top `+sqlRootIn(ledger.IncomeAccount)+`)
+			-
+			sum(amount) filter (where top `+sqlRootIn(ledger.ExpenseAccount)+`)
+		`) + `,
+		'-'
+	) || char(10) as net_income,
+
+	coalesce(
+		'资产：' || char(10) || ` + kinds(ledger.AssetAccount, "currency || "+sqliteDecimal("amount")+" || ' ' || kind"+
+		" || char(10) || '= 增' || "+sqliteDecimal("pos_amount")+" || ' 减' || "+sqliteDecimal("neg_amount"), "desc") + `,
+		'-'
+	) || char(10) as equity,
+
+	coalesce(
+		'负债：' || char(10) || ` + kinds(ledger.LiabilityAccount, "currency || "+sqliteDecimal("amount")+" || ' ' || kind"+
+		" || char(10) || '= 还' || "+sqliteDecimal("pos_amount")+" || ' 借' || "+sqliteDecimal("neg_amount"), "asc") + `,
+		'-'
+	) || char(10) as liability
+
+	from k
+	group by span, currency
+	order by span desc, currency asc
+	`
+}
+
+// sqliteViews returns the views of the SQL mode for SQLite
+func sqliteViews() []string {
+	liquidCond := `account ->> 0 ` + sqlRootIn(ledger.AssetAccount) + ` and account ->> 1 in (` + sqlQuoteList(liquidAssets) + `)`
+
+	budget := func(interval string, name string) string {
+		cond := `date < date('now', '` + interval + `')`
+		return `
+		,'负债：'
+		|| ` + sqliteSums(`account ->> 0 `+sqlRootIn(ledger.LiabilityAccount)+` and `+cond) + `
+		|| char(10)
+		|| '净资产：'
+		|| ` + sqliteNetAssets(cond) + `
+		|| char(10) || '-----' || char(10)
+		|| ` + sqliteKindSums(`account ->> 0 `+sqlRootIn(ledger.LiabilityAccount)+` and `+cond, "asc") + `
+		|| char(10) || '-----' || char(10)
+		|| (
+			select group_concat(month || ' ' || currency || ` + sqliteDecimal("amount") + `, char(10)) from (
+				select sum(amount) as amount, currency, strftime('%Y-%m-01', date) as month
+				from entries
+				where account ->> 0 ` + sqlRootIn(ledger.LiabilityAccount) + `
+				and ` + cond + `
+				group by month, currency
+				order by month asc
+			) t0
+			where amount <> 0
+			and month >= strftime('%Y-%m-01', 'now')
+		)
+		AS ` + name + `预算
+		`
+	}
+
+	return []string{
+		// entries joins postings with transactions and accounts, views below are defined on it
+		`
+		create view entries as
+		select
+		p.id
+		,p.transaction_id as "transaction"
+		,t.payee
+		,t.description as transaction_description
+		,t.date as transaction_date
+		,p.date
+		,a.path as account
+		,p.currency
+		,p.amount
+		,p.description
+		,(
+			select json_group_array(tag) from (
+				select tag from tags where posting_id = p.id order by tag
+			)
+		) as tags
+		,coalesce((
+			select json_group_object(m.key, m.value)
+			from metadata m
+			where m.transaction_id = p.transaction_id
+			and (
+				m.posting_id = p.id
+				or m.posting_id is null and not exists (
+					select 1 from metadata m2 where m2.posting_id = p.id and m2.key = m.key
+				)
+			)
+		), '{}') as metadata
+		from postings p
+		join transactions t on t.id = p.transaction_id
+		join accounts a on a.id = p.account_id
+		`,
+
+		// things
+		`
+		create view things as
+		select * from (
+			select max(date) as date, max(description) as description, max(kinds) as kinds
+			,json_group_object(currency, amount) as amount
+			from (
+				select
+				max(date) as date
+				,"transaction"
+				,max(transaction_description) as description
+				,json_group_array(account ->> 1) as kinds
+				,currency
+				,sum(amount) as amount
+				from entries
+				where account ->> 0 ` + sqlRootIn(ledger.ExpenseAccount) + `
+				and account ->> 1 in (` + sqlQuoteList(thingKinds()) + `)
+				group by "transaction", currency
+			) t0
+			group by "transaction"
+		) t1
+		order by date desc, description
+		`,
+
+		// consumables
+		`
+		create view consumables as
+		select date, max(transaction_description) as transaction_description
+		from entries
+		where account ->> 0 ` + sqlRootIn(ledger.ExpenseAccount) + `
+		and account ->> 1 in (` + sqlQuoteList(consumablesViewKinds) + `)
+		group by date, "transaction"
+		order by date desc, "transaction"
+		`,
+
+		"create view yearly as" + sqliteIntervalStat("strftime('%Y-01-01', date)"),
+
+		"create view seasonally as" + sqliteIntervalStat("date(strftime('%Y-01-01', date), '+' || (cast(strftime('%m', date) as integer) / 3 * 3) || ' months')"),
+
+		"create view monthly as" + sqliteIntervalStat("strftime('%Y-%m-01', date)"),
+
+		"create view weekly as" + sqliteIntervalStat("date(date, 'weekday 0', '-6 days')"),
+
+		"create view daily as" + sqliteIntervalStat("date(date)"),
+
+		// this year expenses
+		`
+		create view yearly_expenses as
+		select
+		strftime('%Y', date) as year, currency, sum(amount) as sum, account ->> 1 as kind,
+		json_group_array(
+			date
+			|| ' '
+			|| currency
+			|| ` + sqliteDecimal("amount") + `
+			|| ' '
+			|| transaction_description
+		) as entries
+		from (
+			select * from entries
+			where account ->> 0 ` + sqlRootIn(ledger.ExpenseAccount) + `
+			order by amount desc, date desc
+		) t0
+		group by year, kind, currency
+		order by year desc, sum desc
+		`,
+
+		// balance sheet
+		`
+		create view balance_sheet as
+		select
+		` + sqliteSums(`account ->> 0 `+sqlRootIn(ledger.AssetAccount)) + `
+		|| char(10) || '-----' || char(10)
+		|| ` + sqliteKindSums(`account ->> 0 `+sqlRootIn(ledger.AssetAccount), "desc") + `
+		AS 资产
+
+		,` + sqliteSums(`account ->> 0 `+sqlRootIn(ledger.LiabilityAccount)) + `
+		|| char(10) || '-----' || char(10)
+		|| ` + sqliteKindSums(`account ->> 0 `+sqlRootIn(ledger.LiabilityAccount), "asc") + `
+		AS 负债
+
+		,` + sqliteNetAssets("true") + `
+		AS 净资产
+
+		` + budget("+1 year", "一年") + budget("+3 years", "三年") + `
+
+		,` + sqliteSums(liquidCond) + `
+		|| char(10) || '-----' || char(10)
+		|| ` + sqliteKindSums(liquidCond, "desc") + `
+		AS "T+0流动资产"
+
+		,` + sqliteSums(`account ->> 0 `+sqlRootIn(ledger.LiabilityAccount)+` and date < date('now', '+1 month')`) + `
+		|| char(10) || '-----' || char(10)
+		|| ` + sqliteKindSums(`account ->> 0 `+sqlRootIn(ledger.LiabilityAccount)+` and date < date('now', '+1 month')`, "asc") + `
+		AS 一月负债
+		`,
+
+		// net_asset_changes
+		`
+		create view net_asset_changes as
+		select
+		strftime('%Y-%m', d) AS 月份,
+		cast(julianday(d) - julianday(lag(d, 1) over (partition by c order by d asc)) as integer) as 日数,
+		asset as 资产,
+		liability as 负债,
+		asset + liability AS 净资产,
+		c,
+		(asset + liability) - lag(asset + liability, 1) over (partition by c order by d asc) as 变动
+		from (
+			select
+			c,
+			d,
+			coalesce((
+				select sum(amount)
+				from entries
+				where account ->> 0 ` + sqlRootIn(ledger.AssetAccount) + `
+				and currency = c
+				and date < d
+			), 0) as asset,
+			coalesce((
+				select sum(amount)
+				from entries
+				where account ->> 0 ` + sqlRootIn(ledger.LiabilityAccount) + `
+				and currency = c
+				and transaction_date < d
+			), 0) as liability
+			from (
+				select distinct strftime('%Y-%m-01', date) as d, currency as c from entries
+				where currency in ('￥', '$')
+			) t0
+			where d < date('now')
+		) t1
+		order by d desc
+		`,
+
+		// assurance
+		`
+		create view assurance as
+		select
+		year, d, sum(amount) as sum
+		from (
+			select
+			*,
+			strftime('%Y', date) as year,
+			(
+				select (b.account ->> 2) || '：' || (b.account ->> 3) from entries b
+				where b."transaction" = entries."transaction"
+				and b.account ->> 0 = '保险' and b.account ->> 1 = '生效'
+			) as d
+			from entries
+			where account ->> 0 ` + sqlRootIn(ledger.ExpenseAccount) + ` and account ->> 1 = '保险'
+		) t0
+		group by d, year
+		order by year asc, sum(amount) desc
+		`,
+
+		// foods
+		`
+		create view foods as
+		select account, amount
+		from (
+			select account ->> 1 as account, abs(sum(amount)) as amount
+				,max(date) filter (where account ->> 0 = '消耗品购买') as date
+			from entries
+			where
+			(
+				account ->> 0 = '消耗品购买'
+				or
+				account ->> 0 = '消耗品已用'
+			)
+			and account ->> 1 in (
+				select account ->> 1
+				from entries
+				where "transaction" in (
+					select "transaction"
+					from entries
+					where account ->> 0 ` + sqlRootIn(ledger.ExpenseAccount) + `
+					and account ->> 1 = '饮食'
+				)
+				and account ->> 0 = '消耗品购买'
+			)
+			group by account ->> 1
+		) t0
+		where amount <> 0
+		order by date asc
+		`,
+
+		// funds
+		`
+		create view funds as
+		select *
+		,round(cast(account ->> 4 as numeric) * max(
+			power(1.30, (julianday('now') - julianday(date)) / 365),
+			1.06
+		), 4) as target
+		from (
+			select
+			max(date) as date
+			,account
+			,sum(amount) as amount
+			from entries
+			where account ->> 0 ` + sqlRootIn(ledger.AssetAccount) + `
+			and account ->> 1 like '%股基%'
+			and account ->> 4 glob '*[0-9].[0-9]*'
+			group by account
+		) ts
+		where amount > 0
+		order by account, target
+		`,
+
+		// equity
+		`
+		create view equity as
+		select date, sum as "￥"
+		from (
+			select
+			date,
+			"transaction",
+			sum(amount) over (order by "transaction" asc) as sum,
+			row_number() over (partition by date order by "transaction" desc) as n
+			from entries
+			where date < date('now')
+			and account ->> 0 ` + sqlRootIn(ledger.AssetAccount) + `
+			and currency = '￥'
+		) t0
+		where n = 1
+		order by date desc
+		`,
+	}
+}
@@ -0,0 +1,276 @@
+package main
+
+import (
+	"fmt"
+	"math/big"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/reusee/keep/ledger"
+)
+
+var (
+	sharePricePattern = regexp.MustCompile(`[0-9]+\.[0-9]{3}`)
+	monthPattern      = regexp.MustCompile(`^[0-9x]{4}$`)
+	datePattern       = regexp.MustCompile(`[0-9]{6}`)
+)
+
+// printTree prints the account tree, with market values and unrealized gains if valuation is not nil.
+// Subaccounts starting more than about 3 months after date are folded
+func printTree(rootAccount *ledger.Account, noAmount bool, valuation *ledger.Valuation, date time.Time) {
+	// calculate proportions
+	var calculateProportion func(*ledger.Account)
+	calculateProportion = func(account *ledger.Account) {
+		for _, sub := range account.Subs {
+			for currency, balance := range sub.Balances {
+				if account.Balances[currency].Sign() != 0 {
+					b := big.NewRat(0, 1)
+					b.Set(balance)
+					sub.Proportions[currency] = b.Quo(balance, account.Balances[currency])
+					b.Abs(b)
+				}
+				calculateProportion(sub)
+			}
+		}
+	}
+	calculateProportion(rootAccount)
+
+	// print accounts
+	var printAccount func(account *ledger.Account, level int, nameLen int)
+	printAccount = func(account *ledger.Account, level int, nameLen int) {
+		allZero := true
+		for _, balance := range account.Balances {
+			abs := new(big.Rat)
+			abs.Set(balance)
+			abs.Abs(abs)
+			if balance.Cmp(zeroRat) != 0 && abs.Cmp(oneCent) >= 0 {
+				allZero = false
+				break
+			}
+		}
+		if allZero && account != rootAccount {
+			return
+		}
+		pt(
+			"%s%s",
+			strings.Repeat(" │    ", level),
+			padToLen(account.Name, nameLen),
+		)
+		var currencyNames []string
+		for name := range account.Balances {
+			currencyNames = append(currencyNames, name)
+		}
+		sort.Strings(currencyNames)
+		for _, name := range currencyNames {
+			balance := account.Balances[name]
+			var proportion string
+			if p, ok := account.Proportions[name]; ok {
+				proportion = " " + p.Mul(p, big.NewRat(100, 1)).FloatString(3) + "%"
+			}
+			if !noAmount {
+				if name == "/" {
+					pt(" %s", ledger.FormatAmount(name, balance, 0))
+				} else {
+					pt(" %s", ledger.FormatAmount(name, balance, 2))
+				}
+			}
+			pt("%s", proportion)
+		}
+		if valuation != nil && !noAmount {
+			pt(" = %s", ledger.FormatAmount(valuation.Currency, valuation.Values[account], 2))
+			if gain, ok := valuation.Gain(account); ok {
+				if gain.Sign() != 0 {
+					sign := ""
+					if gain.Sign() > 0 {
+						sign = "+"
+					}
+					pt(" (%s%s)", sign, gain.FloatString(2))
+				}
+			} else {
+				var commodities []string
+				commodities = append(commodities, valuation.Missing[account]...)
+				commodities = append(commodities, valuation.Uncosted[account]...)
+				pt(" (? %s)", strings.Join(commodities, " "))
+			}
+		}
+		pt("\n")
+
+		var subNames []string
+		for name := range account.Subs {
+			subNames = append(subNames, name)
+		}
+
+		allIsLeaf := func() bool {
+			for _, sub := range account.Subs {
+				sum := big.NewRat(0, 1)
+				for _, balance := range sub.Balances {
+					sum.Add(sum, balance)
+				}
+				if sum.Sign() == 0 {
+					continue
+				}
+				if len(sub.Subs) > 0 {
+					return false
+				}
+			}
+			return true
+		}()
+		allIsMonths := func() bool {
+			if !allIsLeaf {
+				return false
+			}
+			for _, name := range subNames {
+				sub := account.Subs[name]
+				sum := big.NewRat(0, 1)
+				for _, balance := range sub.Balances {
+					sum.Add(sum, balance)
+				}
+				if sum.Sign() == 0 {
+					continue
+				}
+				if !monthPattern.MatchString(name) {
+					return false
+				}
+			}
+			return true
+		}()
+		allIsDate := func() bool {
+			if !allIsLeaf {
+				return false
+			}
+			for _, name := range subNames {
+				sub := account.Subs[name]
+				sum := big.NewRat(0, 1)
+				for _, balance := range sub.Balances {
+					sum.Add(sum, balance)
+				}
+				if sum.Sign() == 0 {
+					continue
+				}
+				if !datePattern.MatchString(name) {
+					return false
+				}
+			}
+			return true
+		}()
+		allIsSharePrices := func() bool {
+			if !allIsLeaf {
+				return false
+			}
+			for _, name := range subNames {
+				sub := account.Subs[name]
+				sum := big.NewRat(0, 1)
+				for _, balance := range sub.Balances {
+					sum.Add(sum, balance)
+				}
+				if sum.Sign() == 0 {
+					continue
+				}
+				if !sharePricePattern.MatchString(name) {
+					return false
+				}
+			}
+			return true
+		}()
+
+		sort.SliceStable(subNames, func(i, j int) bool {
+			a := account.Subs[subNames[i]]
+			b := account.Subs[subNames[j]]
+			weightA, ok1 := sortWeight[sortWeightKey{level + 1, a.Name}]
+			weightB, ok2 := sortWeight[sortWeightKey{level + 1, b.Name}]
+			if ok1 && !ok2 {
+				return false
+			} else if !ok1 && ok2 {
+				return true
+			} else if ok1 && ok2 {
+				return weightA < weightB
+			}
+			// '/' 为单位的
+			if len(account.Balances) == 1 && func() bool {
+				for currency := range account.Balances {
+					if currency == "/" {
+						return true
+					}
+				}
+				return false
+			}() {
+				return a.Name < b.Name
+			}
+			if allIsMonths || allIsDate {
+				// 月份排序
+				return subNames[i] < subNames[j]
+			}
+			if allIsSharePrices {
+				// 价格排序
+				priceA := new(big.Rat)
+				priceA, ok := priceA.SetString(subNames[i])
+				if !ok {
+					panic(fmt.Sprintf("bad price: %s", subNames[i]))
+				}
+				priceB := new(big.Rat)
+				priceB, ok = priceB.SetString(subNames[j])
+				if !ok {
+					panic(fmt.Sprintf("bad price: %s", subNames[j]))
+				}
+				return priceA.Cmp(priceB) > 0
+			}
+			if level == 0 {
+				return subNames[i] < subNames[j]
+			}
+			// 金额排序
+			sumA := big.NewRat(0, 1)
+			for _, balance := range a.Balances {
+				sumA.Add(sumA, balance)
+			}
+			sumB := big.NewRat(0, 1)
+			for _, balance := range b.Balances {
+				sumB.Add(sumB, balance)
+			}
+			return sumA.Cmp(sumB) > 0
+		})
+		subNameLen := 0
+		for _, name := range subNames {
+			l := displayWidth(name)
+			if l > subNameLen {
+				subNameLen = l
+			}
+		}
+		skip := false
+		for _, name := range subNames {
+			subAccount := account.Subs[name]
+			if subAccount.TimeFrom.Sub(date) > time.Hour*24*30*3 {
+				skip = true
+				continue
+			}
+			printAccount(subAccount, level+1, subNameLen)
+		}
+		if skip {
+			pt(
+				"%s[...]\n",
+				strings.Repeat(" │    ", level+1),
+			)
+		}
+	}
+	printAccount(rootAccount, 0, 0)
+}
+
+// printConvertedTree prints the account tree with balances converted to currency at date, and the net worth
+func printConvertedTree(l *ledger.Ledger, currency string, date time.Time, noAmount bool) {
+	valuation := l.Valuate(currency, date)
+	printTree(valuation.Tree(l.Root), noAmount, nil, date)
+
+	netWorth := new(big.Rat)
+	for _, name := range append(l.AccountTypes.Roots(ledger.AssetAccount), l.AccountTypes.Roots(ledger.LiabilityAccount)...) {
+		if account, ok := l.Root.Subs[name]; ok {
+			netWorth.Add(netWorth, valuation.Values[account])
+		}
+	}
+	if !noAmount {
+		pt("净资产 %s\n", ledger.FormatAmount(currency, netWorth, 2))
+	}
+	if missing := valuation.Missing[l.Root]; len(missing) > 0 {
+		pt("no rate to %s: %s\n", currency, strings.Join(missing, " "))
+	}
+}
@@ -4,8 +4,10 @@ import (
 	"fmt"
 	"math/big"
 	"os/user"
+	"strings"
 
 	"github.com/reusee/e/v2"
+	"golang.org/x/text/width"
 )
 
 var (
@@ -24,3 +26,27 @@ var (
 type (
 	any = interface{}
 )
+
+func displayWidth(s string) int {
+	l := 0
+	for _, r := range s {
+		properties := width.LookupRune(r)
+		switch properties.Kind() {
+		case width.EastAsianWide, width.EastAsianFullwidth:
+			l += 2
+		default:
+			l += 1
+		}
+	}
+	return l
+}
+
+func padToLen(s string, l int) string {
+	b := new(strings.Builder)
+	b.WriteString(s)
+	l -= displayWidth(s)
+	for ; l > 0; l-- {
+		b.WriteByte(' ')
+	}
+	return b.String()
+}